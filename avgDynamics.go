@@ -1,24 +1,85 @@
 package keyize
 
+import (
+	"math"
+	"sort"
+)
+
+type AggregationMethod int
+
+const (
+	// AggregateMean is the arithmetic mean of the values of a property
+	AggregateMean AggregationMethod = iota
+
+	// AggregateWeightedMean is the mean of the values of a property weighted by their Stats.Count,
+	// so a Dynamics with many occurrences of a property counts for more than one with few.
+	//
+	// Properties without Stats have a weight of 1. As extraction sets Stats only if ExtractOptions.Stats is set,
+	// the Dynamics must be extracted with it (eg. using DynamicsWithOptions), or this is the same as AggregateMean.
+	AggregateWeightedMean
+
+	// AggregateMedian is the median of the values of a property
+	AggregateMedian
+
+	// AggregateTrimmedMean is the mean of the values of a property, excluding the Trim proportion of the lowest
+	// and highest values
+	AggregateTrimmedMean
+
+	// AggregateWinsorizedMean is the mean of the values of a property, after replacing the Trim proportion of the lowest
+	// and highest values with the nearest remaining values
+	AggregateWinsorizedMean
+)
+
+// DefaultTrim is the proportion of values trimmed from each end by AggregateTrimmedMean and AggregateWinsorizedMean
+// if AggregateOptions.Trim is zero.
+const DefaultTrim = 0.1
+
+// AggregateOptions configures AggregateDynamics.
+type AggregateOptions struct {
+	Method AggregationMethod
+
+	// Trim is the proportion of values trimmed from each end by AggregateTrimmedMean and AggregateWinsorizedMean.
+	// If zero, DefaultTrim is used. At least one value (or two, for an even count) always remains.
+	Trim float64
+}
+
 // AvgDynamics returns a pointer to a new Dynamics which is the average of all Dynamics contained from d.
+//
+// The Stats of each property describe the distribution of its values across d.
+// To use a more robust average, use AggregateDynamics.
 func AvgDynamics(d []*Dynamics) *Dynamics {
+	return AggregateDynamics(d, nil)
+}
+
+// AggregateDynamics returns a pointer to a new Dynamics which aggregates all Dynamics contained from d
+// using the method of AggregateOptions opts. If opts is nil, the arithmetic mean is used, as done by AvgDynamics.
+//
+// The Stats of each property describe the distribution of its values across d.
+func AggregateDynamics(d []*Dynamics, opts *AggregateOptions) *Dynamics {
+	if opts == nil {
+		opts = &AggregateOptions{}
+	}
+
 	propSet := newFloatSliceMapMan()
+	weightSet := newFloatSliceMapMan()
 
 	for _, c := range d {
 		for propName, p := range c.properties {
 			propSet.Add(propName, p.Value)
+
+			if p.Stats != nil && p.Stats.Count > 0 {
+				weightSet.Add(propName, float64(p.Stats.Count))
+			} else {
+				weightSet.Add(propName, 1)
+			}
 		}
 	}
 
-	// Average values
-
-	avgPropValues := propSet.Reduce()
-
 	// Create the new Dynamics
 
 	n := NewDynamics()
 
-	for key, v := range avgPropValues {
+	for key, values := range propSet {
 		// There should be no error as the internally managed properties should be accurate and fully vetted
 		prop, err := ParseDynamicsPropertyName(key)
 
@@ -26,10 +87,115 @@ func AvgDynamics(d []*Dynamics) *Dynamics {
 			panic(err)
 		}
 
-		prop.Value = v
+		prop.Value = aggregate(values, weightSet[key], opts)
+		prop.Stats = sliceStats(values)
 
 		n.AddProperty(prop)
 	}
 
 	return n
 }
+
+// aggregate aggregates values, which have weights, using the method of AggregateOptions opts.
+func aggregate(values []float64, weights []float64, opts *AggregateOptions) float64 {
+	switch opts.Method {
+	case AggregateWeightedMean:
+		sum := 0.0
+		totalWeight := 0.0
+
+		for i, v := range values {
+			sum += v * weights[i]
+			totalWeight += weights[i]
+		}
+
+		return sum / totalWeight
+	case AggregateMedian:
+		return median(values)
+	case AggregateTrimmedMean, AggregateWinsorizedMean:
+		trim := opts.Trim
+
+		if trim == 0 {
+			trim = DefaultTrim
+		}
+
+		sorted := make([]float64, len(values))
+		copy(sorted, values)
+
+		sort.Float64s(sorted)
+
+		// Count of values trimmed from each end
+
+		k := int(trim * float64(len(sorted)))
+
+		if max := (len(sorted) - 1) / 2; k > max {
+			k = max
+		}
+
+		if k < 0 {
+			k = 0
+		}
+
+		if opts.Method == AggregateTrimmedMean {
+			return mean(sorted[k : len(sorted)-k])
+		}
+
+		for i := 0; i < k; i++ {
+			sorted[i] = sorted[k]
+			sorted[len(sorted)-1-i] = sorted[len(sorted)-1-k]
+		}
+
+		return mean(sorted)
+	default:
+		return mean(values)
+	}
+}
+
+// mean returns the arithmetic mean of values.
+func mean(values []float64) float64 {
+	t := 0.0
+
+	for _, v := range values {
+		t += v
+	}
+
+	return t / float64(len(values))
+}
+
+// sliceStats returns PropertyStats describing values. As every value is available, the median is exact
+// and the mean absolute deviation is known.
+func sliceStats(values []float64) *PropertyStats {
+	a := &statsAccumulator{}
+
+	for _, v := range values {
+		a.add(v)
+	}
+
+	s := a.stats()
+	s.Median = median(values)
+
+	for _, v := range values {
+		s.MeanAbsDev += math.Abs(v - a.mean)
+	}
+
+	s.MeanAbsDev /= float64(len(values))
+
+	return s
+}
+
+// median returns the median of values, which is 0 if there are none.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+
+	sort.Float64s(sorted)
+
+	if len(sorted)%2 == 0 {
+		return (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	return sorted[len(sorted)/2]
+}
@@ -0,0 +1,53 @@
+package keyize
+
+import (
+	"testing"
+)
+
+func TestAggregateDynamics(t *testing.T) {
+	// Five sessions, one of which is sloppy
+
+	var sessions []*Dynamics
+
+	for i, v := range []float64{100, 110, 90, 105, 400} {
+		d := NewDynamics()
+
+		d.AddPropertyByName("DD.t.h", v)
+
+		// Occurrences of the property in each session
+
+		d.Properties()["DD.t.h"].Stats = &PropertyStats{Count: []int{10, 10, 10, 10, 1}[i]}
+
+		sessions = append(sessions, d)
+	}
+
+	expected := map[AggregationMethod]float64{
+		AggregateMean:           161,
+		AggregateWeightedMean:   (1000 + 1100 + 900 + 1050 + 400) / 41.0,
+		AggregateMedian:         105,
+		AggregateTrimmedMean:    105,
+		AggregateWinsorizedMean: 105,
+	}
+
+	for method, v := range expected {
+		p := AggregateDynamics(sessions, &AggregateOptions{Method: method, Trim: 0.2}).Properties()["DD.t.h"]
+
+		if p.Value != v {
+			t.Errorf("method %d produced %f, expected %f", method, p.Value, v)
+		}
+
+		if p.Stats.Count != 5 || p.Stats.Max != 400 {
+			t.Errorf("method %d produced incorrect stats %+v", method, p.Stats)
+		}
+	}
+
+	// Trimming never removes every value
+
+	if v := AggregateDynamics(sessions[:2], &AggregateOptions{Method: AggregateTrimmedMean, Trim: 0.5}).Properties()["DD.t.h"].Value; v != 105 {
+		t.Errorf("trimmed mean of two values produced %f", v)
+	}
+
+	if v := AvgDynamics(sessions).Properties()["DD.t.h"].Value; v != 161 {
+		t.Errorf("AvgDynamics produced %f", v)
+	}
+}
@@ -0,0 +1,191 @@
+// Package datasets provides importers for public keystroke dynamics benchmark datasets.
+package datasets
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/KeyizeBiometry/keyize"
+)
+
+// cmuKeyRuneMap maps key names used by the CMU dataset to the runes used by keyize.
+var cmuKeyRuneMap = map[string]rune{
+	"Shift":  keyize.KeyShift,
+	"period": '.',
+	"five":   '5',
+	"Return": keyize.KeyEnter,
+}
+
+// CMUSample is a single repetition of the password typed by a subject in the CMU benchmark.
+type CMUSample struct {
+	Subject string
+
+	// Session is the value of the sessionIndex column
+	Session int
+
+	// Rep is the index of the repetition within the session
+	Rep int
+
+	Dynamics *keyize.Dynamics
+}
+
+// CMUDataset holds the samples of the CMU benchmark, in the order they were read.
+type CMUDataset struct {
+	Samples []*CMUSample
+}
+
+// Subjects returns the names of all subjects in the order they first appear.
+func (d *CMUDataset) Subjects() []string {
+	var subjects []string
+
+	seen := map[string]bool{}
+
+	for _, s := range d.Samples {
+		if !seen[s.Subject] {
+			seen[s.Subject] = true
+			subjects = append(subjects, s.Subject)
+		}
+	}
+
+	return subjects
+}
+
+// SubjectSamples returns the samples of subject, in the order they were read.
+func (d *CMUDataset) SubjectSamples(subject string) []*CMUSample {
+	var samples []*CMUSample
+
+	for _, s := range d.Samples {
+		if s.Subject == subject {
+			samples = append(samples, s)
+		}
+	}
+
+	return samples
+}
+
+// SubjectDynamics returns the Dynamics of each sample of subject, in the order they were read.
+func (d *CMUDataset) SubjectDynamics(subject string) []*keyize.Dynamics {
+	var dyns []*keyize.Dynamics
+
+	for _, s := range d.SubjectSamples(subject) {
+		dyns = append(dyns, s.Dynamics)
+	}
+
+	return dyns
+}
+
+// cmuPropertyName converts a CMU column name (eg. "H.period", "DD.Shift.r.o") to a DynamicsProperty name.
+//
+// Hold (H) columns become Dwell properties. Columns naming three keys, such as "DD.Shift.r.o",
+// have their middle key dropped, so "DD.Shift.r.o" becomes "DD.r.o".
+func cmuPropertyName(column string) string {
+	segs := strings.Split(column, ".")
+
+	if segs[0] == "H" {
+		segs[0] = "D"
+	}
+
+	for i, seg := range segs {
+		if r, ok := cmuKeyRuneMap[seg]; ok {
+			segs[i] = string(r)
+		}
+	}
+
+	if len(segs) > 3 {
+		segs = []string{segs[0], segs[2], segs[3]}
+	}
+
+	return strings.Join(segs, ".")
+}
+
+// ReadCMU reads the CMU keystroke dynamics benchmark (DSL-StrongPasswordData.csv) from r.
+//
+// The dataset may be downloaded from https://www.cs.cmu.edu/~keystroke/.
+// Timings are converted from seconds to milliseconds.
+func ReadCMU(r io.Reader) (*CMUDataset, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Determine column roles and property names from the header
+
+	subjectCol, sessionCol, repCol := -1, -1, -1
+	propNames := make([]string, len(header))
+
+	for i, col := range header {
+		switch col {
+		case "subject":
+			subjectCol = i
+		case "sessionIndex":
+			sessionCol = i
+		case "rep":
+			repCol = i
+		default:
+			propNames[i] = cmuPropertyName(col)
+
+			if _, err := keyize.ParseDynamicsPropertyName(propNames[i]); err != nil {
+				return nil, errors.New("unsupported CMU column '" + col + "'")
+			}
+		}
+	}
+
+	if subjectCol == -1 || sessionCol == -1 || repCol == -1 {
+		return nil, errors.New("CMU header is missing subject, sessionIndex or rep column")
+	}
+
+	dataset := &CMUDataset{}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		sample := &CMUSample{
+			Subject:  record[subjectCol],
+			Dynamics: keyize.NewDynamics(),
+		}
+
+		if sample.Subject == "" {
+			return nil, errors.New("missing subject on line " + strconv.Itoa(line))
+		}
+
+		if sample.Session, err = strconv.Atoi(record[sessionCol]); err != nil {
+			return nil, errors.New("invalid sessionIndex on line " + strconv.Itoa(line))
+		}
+
+		if sample.Rep, err = strconv.Atoi(record[repCol]); err != nil {
+			return nil, errors.New("invalid rep on line " + strconv.Itoa(line))
+		}
+
+		for i, v := range record {
+			if propNames[i] == "" {
+				continue
+			}
+
+			secondsValue, err := strconv.ParseFloat(v, 64)
+
+			if err != nil {
+				return nil, errors.New("invalid value for " + header[i] + " on line " + strconv.Itoa(line))
+			}
+
+			if err := sample.Dynamics.AddPropertyByName(propNames[i], secondsValue*1000); err != nil {
+				return nil, err
+			}
+		}
+
+		dataset.Samples = append(dataset.Samples, sample)
+	}
+
+	return dataset, nil
+}
@@ -0,0 +1,58 @@
+package datasets
+
+import (
+	"strings"
+	"testing"
+)
+
+const cmuSample = `subject,sessionIndex,rep,H.period,DD.period.t,UD.period.t,H.t,DD.Shift.r.o,H.Return
+s002,1,1,0.1491,0.3979,0.2488,0.1069,0.2212,0.0742
+s002,1,2,0.1111,0.3451,0.2340,0.0694,0.1983,0.0808
+s003,1,1,0.1133,0.6138,0.5005,0.1106,0.2341,0.0886
+`
+
+func TestReadCMU(t *testing.T) {
+	dataset, err := ReadCMU(strings.NewReader(cmuSample))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dataset.Samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(dataset.Samples))
+	}
+
+	subjects := dataset.Subjects()
+
+	if len(subjects) != 2 || subjects[0] != "s002" || subjects[1] != "s003" {
+		t.Fatalf("unexpected subjects %v", subjects)
+	}
+
+	sample := dataset.SubjectSamples("s002")[1]
+
+	if sample.Session != 1 || sample.Rep != 2 {
+		t.Fatalf("unexpected metadata %+v", sample)
+	}
+
+	props := sample.Dynamics.Properties()
+
+	if len(props) != 6 {
+		t.Fatalf("expected 6 properties, got %d", len(props))
+	}
+
+	if v := props["D.."].Value; v < 111.09 || v > 111.11 {
+		t.Errorf("bad D.. value %f", v)
+	}
+
+	if _, ok := props["DD.r.o"]; !ok {
+		t.Error("missing DD.r.o property")
+	}
+
+	if _, ok := props["D.\n"]; !ok {
+		t.Error("missing Return dwell property")
+	}
+
+	if _, err := ReadCMU(strings.NewReader("subject,sessionIndex,rep,H.t\ns002,1,x,0.1\n")); err == nil {
+		t.Error("expected error for invalid rep")
+	}
+}
@@ -4,6 +4,7 @@ package keyize
 
 import (
 	"math"
+	"strconv"
 )
 
 type DynamicsPropertyKind int
@@ -12,8 +13,38 @@ const (
 	Dwell DynamicsPropertyKind = iota
 	DownDown
 	UpDown
+
+	// UpUp is the time from the release of a key to the release of the next key
+	UpUp
+
+	// DownUp is the time from the press of a key to the release of the next key pressed
+	DownUp
+
+	// NGraph is the time from the press of the first to the press of the last of a sequence of three or more keys
+	NGraph
 )
 
+// minNGraphLength is the least number of keys in an NGraph property
+const minNGraphLength = 3
+
+var dynamicsPropertyKindNames = map[DynamicsPropertyKind]string{
+	Dwell:    "Dwell",
+	DownDown: "DownDown",
+	UpDown:   "UpDown",
+	UpUp:     "UpUp",
+	DownUp:   "DownUp",
+	NGraph:   "NGraph",
+}
+
+// String returns the name of DynamicsPropertyKind k, such as "DownDown".
+func (k DynamicsPropertyKind) String() string {
+	if name, ok := dynamicsPropertyKindNames[k]; ok {
+		return name
+	}
+
+	return "DynamicsPropertyKind(" + strconv.Itoa(int(k)) + ")"
+}
+
 // Scaling for use in ProportionMatch.
 // Provides a range for mapping result from AvgScaledPropDiff.
 //
@@ -26,6 +57,14 @@ var defaultDynamicsPropertyKindScaleMap = DynamicsPropertyKindScaleMap{
 	Dwell:    1.1,
 	DownDown: 1 / 19.4,
 	UpDown:   1 / 13.0,
+
+	// UpUp and DownUp were not part of the research. UpUp is distributed like DownDown, and DownUp is a DownDown
+	// plus a Dwell, so the DownDown scale is used for both.
+	UpUp:   1 / 19.4,
+	DownUp: 1 / 19.4,
+
+	// NGraph timings span n-1 DownDown timings, so the DownDown scale is halved for the common case of trigraphs
+	NGraph: 1 / 38.8,
 }
 
 // DynamicsPropertyKindScaleMap is a map of DynamicsPropertyKind to scaling values.
@@ -35,6 +74,39 @@ var defaultDynamicsPropertyKindScaleMap = DynamicsPropertyKindScaleMap{
 // Using the optimized default scale values by passing nil to distance methods for the propertyKindScaleMap argument is recommended.
 type DynamicsPropertyKindScaleMap map[DynamicsPropertyKind]float64
 
+// scale returns the scale for DynamicsPropertyKind k from DynamicsPropertyKindScaleMap m, or the default if m does not include k.
+func (m DynamicsPropertyKindScaleMap) scale(k DynamicsPropertyKind) float64 {
+	scale, ok := m[k]
+
+	if !ok {
+		scale, ok = defaultDynamicsPropertyKindScaleMap[k]
+
+		if !ok {
+			// Default map and provided map do not include scale for kind
+			// This should not occur unless the user has provided an invalid Dynamics which contains a custom DynamicsPropertyKind.
+
+			panic("could not find scale for DynamicsPropertyKind provided")
+		}
+	}
+
+	return scale
+}
+
+// withDefaults returns a copy of the default DynamicsPropertyKindScaleMap, overridden by the entries of m.
+func (m DynamicsPropertyKindScaleMap) withDefaults() DynamicsPropertyKindScaleMap {
+	merged := DynamicsPropertyKindScaleMap{}
+
+	for k, scale := range defaultDynamicsPropertyKindScaleMap {
+		merged[k] = scale
+	}
+
+	for k, scale := range m {
+		merged[k] = scale
+	}
+
+	return merged
+}
+
 type SharedPropertiesMethod int
 
 const (
@@ -51,21 +123,47 @@ const (
 // DynamicsProperty is a keystroke dynamics property, often synthesized from
 // a Recording.
 type DynamicsProperty struct {
-	Kind  DynamicsPropertyKind
-	KeyA  rune
-	KeyB  rune
+	Kind DynamicsPropertyKind
+	KeyA rune
+	KeyB rune
+
+	// Keys holds the sequence of keys of an NGraph property, whose first and last keys are also KeyA and KeyB.
+	// It is nil for other kinds.
+	Keys []rune
+
 	Value float64
+
+	// Stats, if not nil, describes the distribution of the timings from which Value was computed
+	Stats *PropertyStats
 }
 
 // Name provides the name for the property (this can be used as a key by a Dynamics)
 func (d *DynamicsProperty) Name() string {
 	switch d.Kind {
 	case Dwell:
-		return "D." + string(d.KeyA)
+		return "D." + keyName(d.KeyA)
 	case DownDown:
-		return "DD." + string(d.KeyA) + "." + string(d.KeyB)
+		return "DD." + keyName(d.KeyA) + "." + keyName(d.KeyB)
 	case UpDown:
-		return "UD." + string(d.KeyA) + "." + string(d.KeyB)
+		return "UD." + keyName(d.KeyA) + "." + keyName(d.KeyB)
+	case UpUp:
+		return "UU." + keyName(d.KeyA) + "." + keyName(d.KeyB)
+	case DownUp:
+		return "DU." + keyName(d.KeyA) + "." + keyName(d.KeyB)
+	case NGraph:
+		if len(d.Keys) < minNGraphLength {
+			panic("cannot create a name for an NGraph DynamicsProperty with fewer than 3 keys")
+		}
+
+		// eg. "DD3.t.h.e"
+
+		name := "DD" + strconv.Itoa(len(d.Keys))
+
+		for _, k := range d.Keys {
+			name += "." + keyName(k)
+		}
+
+		return name
 	default:
 		// An invalid DynamicsProperty is being used.
 		// This should only occur if the user creates their own DynamicsPropertyKind, which should not be done.
@@ -117,10 +215,14 @@ func (d *Dynamics) RemoveProperty(p *DynamicsProperty) {
 }
 
 // ProportionSharedProperties returns the proportion of properties shared between Dynamics d and a
-// with respect to SharedPropertiesMethod method.
+// with respect to SharedPropertiesMethod method. It is 0 if no properties are considered.
 func (d *Dynamics) ProportionSharedProperties(a *Dynamics, method SharedPropertiesMethod) float64 {
 	shared, total := d.SharedProperties(a, method)
 
+	if total == 0 {
+		return 0
+	}
+
 	return float64(shared) / float64(total)
 }
 
@@ -166,78 +268,42 @@ func (d *Dynamics) SharedProperties(a *Dynamics, method SharedPropertiesMethod)
 	return shared, total
 }
 
-func (d *Dynamics) intermediateDist(a *Dynamics, squareDifferences bool, propertyKindScaleMap DynamicsPropertyKindScaleMap) (float64, int) {
-	td := 0.0
-	count := 0
-
-	for timingName, t1 := range d.properties {
-		t2, ok := a.properties[timingName]
-
-		if !ok {
-			// Property not shared
-			continue
-		}
-
-		// Perform scaling
-
-		scaleProp, ok := propertyKindScaleMap[t1.Kind]
-
-		if !ok {
-			scaleProp, ok = defaultDynamicsPropertyKindScaleMap[t1.Kind]
-
-			if !ok {
-				// Default map and provided map do not include scale for kind
-				// This should not occur unless the user has provided an invalid Dynamics which contains a custom DynamicsPropertyKind.
-
-				panic("could not find scale for DynamicsPropertyKind provided")
-			}
-		}
-
-		scaledT1Value := t1.Value * scaleProp
-		scaledT2Value := t2.Value * scaleProp
-
-		// Calculate total using scaled values
-
-		if squareDifferences {
-			td += math.Pow(scaledT1Value-scaledT2Value, 2)
-		} else {
-			td += math.Abs(scaledT1Value - scaledT2Value)
-		}
-
-		count++
-	}
-
-	return td, count
-}
-
 // ManhattanDist uses the Manhattan distance metric to find distance between Dynamics d and a.
 //
 // It uses propertyKindScaleMap for scaling. nil may be passed for propertyKindScaleMap and the optimized defaults will be used.
+// If d and a share no properties, +Inf is returned.
 func (d *Dynamics) ManhattanDist(a *Dynamics, propertyKindScaleMap DynamicsPropertyKindScaleMap) (dist float64) {
-	idist, _ := d.intermediateDist(a, false, propertyKindScaleMap)
-
-	return idist
+	return d.Distance(a, Manhattan, &DistanceOptions{Scale: propertyKindScaleMap})
 }
 
 // EuclideanDist uses the Euclidean distance metric to find distance between Dynamics d and a.
 //
 // It uses propertyKindScaleMap for scaling. nil may be passed for propertyKindScaleMap and the optimized defaults will be used.
+// If d and a share no properties, +Inf is returned.
 func (d *Dynamics) EuclideanDist(a *Dynamics, propertyKindScaleMap DynamicsPropertyKindScaleMap) (dist float64) {
-	idist, _ := d.intermediateDist(a, true, propertyKindScaleMap)
-
-	euclideanDist := math.Sqrt(idist)
+	return d.Distance(a, Euclidean, &DistanceOptions{Scale: propertyKindScaleMap})
+}
 
-	return euclideanDist
+// ScaledManhattanDist uses the scaled Manhattan distance metric to find distance between Dynamics d, which should be
+// an enrolled template such as from AvgDynamics, and a.
+//
+// As in the scaled Manhattan detector of Killourhy and Maxion, the absolute difference of each shared property is divided
+// by the mean absolute deviation of the property in d, found in its Stats. Properties whose mean absolute deviation is
+// unknown, such as those of a template built from a single session or which did not vary, are scaled using
+// propertyKindScaleMap instead. nil may be passed for propertyKindScaleMap and the optimized defaults will be used.
+// If d and a share no properties, +Inf is returned.
+func (d *Dynamics) ScaledManhattanDist(a *Dynamics, propertyKindScaleMap DynamicsPropertyKindScaleMap) (dist float64) {
+	return d.Distance(a, ScaledManhattan, &DistanceOptions{Scale: propertyKindScaleMap})
 }
 
 // AvgScaledPropDiff returns the average distance between scaled values of properties shared between Dynamics d and a.
+// If d and a share no properties, +Inf is returned.
 func (d *Dynamics) AvgScaledPropDiff(a *Dynamics, propertyKindScaleMap DynamicsPropertyKindScaleMap) float64 {
-	idist, count := d.intermediateDist(a, false, propertyKindScaleMap)
-
-	return idist / float64(count)
+	return d.Distance(a, AvgDiff, &DistanceOptions{Scale: propertyKindScaleMap})
 }
 
 // ProportionMatch returns a usable match proportion between Dynamics d and a, on a scale of 0.0 to 1.0.
+// If d and a share no properties, 0 is returned.
 func (d *Dynamics) ProportionMatch(a *Dynamics) float64 {
 	avgScaledPropDiff := d.AvgScaledPropDiff(a, nil)
 
@@ -0,0 +1,331 @@
+package keyize
+
+import (
+	"time"
+	"unicode"
+)
+
+// ExtractOptions configures the extraction of Dynamics from a Recording by DynamicsWithOptions.
+// The zero value extracts every property in the same way as Dynamics.
+type ExtractOptions struct {
+	// MaxGap, if not zero, is the longest timing between consecutive keys (of any kind other than Dwell) extracted.
+	// Longer timings, such as those spanning pauses in typing, are discarded.
+	MaxGap time.Duration
+
+	// MinOccurrences is the least number of timings a property must have to be included
+	MinOccurrences int
+
+	// Kinds holds the kinds of property to extract. If nil, every kind is extracted.
+	Kinds []DynamicsPropertyKind
+
+	// NGraphs holds the lengths of the key sequences for which NGraph properties are extracted (eg. []int{3} for trigraphs).
+	// Lengths less than 3 are ignored. With MaxGap, sequences do not span longer DownDown timings.
+	NGraphs []int
+
+	// FoldCase keys events by the lower case of their key, so "a" and "A" produce the same properties
+	FoldCase bool
+
+	// IgnoreKeys holds keys whose events are skipped, as if they were not typed (eg. KeyShift).
+	// They are compared after case folding.
+	IgnoreKeys []rune
+
+	// Physical keys events by their physical key, as done by PhysicalDynamics
+	Physical bool
+
+	// Repeats determines how auto-repeats are handled
+	Repeats RepeatPolicy
+
+	// Stats sets the Stats of each property, describing the distribution of its timings
+	Stats bool
+}
+
+// extractedProperty identifies a DynamicsProperty during extraction.
+type extractedProperty struct {
+	kind DynamicsPropertyKind
+	keyA rune
+	keyB rune
+
+	// keys holds the keys of an NGraph property as a string
+	keys string
+}
+
+// extractedTimings accumulates the timings of an extracted property.
+type extractedTimings struct {
+	count int
+	mean  float64
+
+	// stats is nil unless ExtractOptions.Stats is set, so the median and deviation are not estimated unnecessarily
+	stats *statsAccumulator
+}
+
+// heldKey is the state of a held key.
+type heldKey struct {
+	// key is the key of the press
+	key rune
+
+	// at is the At value of the press, or of the last auto-repeat with KeepRepeats
+	at int
+
+	// hasPrev is true if a key was pressed before this one, with key prev at prevAt
+	hasPrev bool
+	prev    rune
+	prevAt  int
+}
+
+// dynamicsExtractor is a single-pass state machine which extracts property timings from the events of a Recording.
+//
+// Held keys are tracked so each KeyUp is paired with the press of the same key, even when several keys are held at once
+// (rollover). Keys are identified by their Code when known, and a release of another case than its press (eg. after Shift
+// is released) is paired with the press. Each event is processed in constant time, so extraction is linear in the
+// length of the recording.
+type dynamicsExtractor struct {
+	rec  *Recording
+	opts *ExtractOptions
+
+	// kinds and ignored hold the sets of ExtractOptions Kinds and IgnoreKeys. kinds is nil if every kind is extracted.
+	kinds   map[DynamicsPropertyKind]bool
+	ignored map[rune]bool
+
+	held map[holdKey]*heldKey
+
+	hasDown    bool
+	lastDown   rune
+	lastDownAt int
+
+	hasUp    bool
+	lastUp   rune
+	lastUpAt int
+
+	// downs holds the keys and At values of the most recent KeyDowns, as needed for NGraph properties
+	downs   []rune
+	downAts []int
+
+	// maxNGraph is the greatest length in ExtractOptions NGraphs
+	maxNGraph int
+
+	timings map[extractedProperty]*extractedTimings
+}
+
+// newDynamicsExtractor returns a new dynamicsExtractor for the events of Recording rec using ExtractOptions opts.
+func newDynamicsExtractor(rec *Recording, opts *ExtractOptions) *dynamicsExtractor {
+	x := &dynamicsExtractor{
+		rec:     rec,
+		opts:    opts,
+		ignored: map[rune]bool{},
+		held:    map[holdKey]*heldKey{},
+		timings: map[extractedProperty]*extractedTimings{},
+	}
+
+	if opts.Kinds != nil {
+		x.kinds = map[DynamicsPropertyKind]bool{}
+
+		for _, k := range opts.Kinds {
+			x.kinds[k] = true
+		}
+	}
+
+	for _, n := range opts.NGraphs {
+		if n > x.maxNGraph {
+			x.maxNGraph = n
+		}
+	}
+
+	for _, k := range opts.IgnoreKeys {
+		if opts.FoldCase {
+			k = unicode.ToLower(k)
+		}
+
+		x.ignored[k] = true
+	}
+
+	return x
+}
+
+// key returns the key of RecordingEvent e.
+func (x *dynamicsExtractor) key(e *RecordingEvent) rune {
+	var k rune
+
+	if x.opts.Physical {
+		k = eventPhysicalKey(e)
+	} else {
+		k = eventSubject(e)
+	}
+
+	if x.opts.FoldCase {
+		k = unicode.ToLower(k)
+	}
+
+	return k
+}
+
+func (x *dynamicsExtractor) isHeld(h holdKey) bool {
+	_, ok := x.held[h]
+
+	return ok
+}
+
+func (x *dynamicsExtractor) addTiming(p extractedProperty, units int) {
+	if x.kinds != nil && !x.kinds[p.kind] {
+		return
+	}
+
+	if x.opts.MaxGap > 0 && p.kind != Dwell && p.kind != NGraph && time.Duration(units)*x.rec.resolution() > x.opts.MaxGap {
+		return
+	}
+
+	t, ok := x.timings[p]
+
+	if !ok {
+		t = &extractedTimings{}
+
+		if x.opts.Stats {
+			t.stats = &statsAccumulator{}
+		}
+
+		x.timings[p] = t
+	}
+
+	v := x.rec.millis(units)
+
+	t.count++
+	t.mean += (v - t.mean) / float64(t.count)
+
+	if t.stats != nil {
+		t.stats.add(v)
+	}
+}
+
+// add processes the next RecordingEvent e, which is an auto-repeat if repeat is true.
+func (x *dynamicsExtractor) add(e *RecordingEvent, repeat bool) {
+	k := x.key(e)
+
+	if x.ignored[k] {
+		return
+	}
+
+	switch e.Kind {
+	case KeyDown:
+		if repeat && x.opts.Repeats == DropRepeats {
+			return
+		}
+
+		if x.hasDown {
+			x.addTiming(extractedProperty{kind: DownDown, keyA: x.lastDown, keyB: k}, e.At-x.lastDownAt)
+		}
+
+		if x.hasUp {
+			x.addTiming(extractedProperty{kind: UpDown, keyA: x.lastUp, keyB: k}, e.At-x.lastUpAt)
+		}
+
+		x.addNGraphs(k, e.At)
+
+		x.held[newHoldKey(e, k)] = &heldKey{
+			key:     k,
+			at:      e.At,
+			hasPrev: x.hasDown,
+			prev:    x.lastDown,
+			prevAt:  x.lastDownAt,
+		}
+
+		x.hasDown = true
+		x.lastDown = k
+		x.lastDownAt = e.At
+	case KeyUp:
+		// A KeyUp of a key which is not held has no press to pair with, so only affects UpDown and UpUp
+
+		if hk, ok := releasedHoldKey(e, k, x.isHeld); ok {
+			h := x.held[hk]
+
+			// The key of the press is used, which may differ in case from that of the release
+			k = h.key
+
+			x.addTiming(extractedProperty{kind: Dwell, keyA: k}, e.At-h.at)
+
+			if h.hasPrev {
+				x.addTiming(extractedProperty{kind: DownUp, keyA: h.prev, keyB: k}, e.At-h.prevAt)
+			}
+
+			delete(x.held, hk)
+		}
+
+		if x.hasUp {
+			x.addTiming(extractedProperty{kind: UpUp, keyA: x.lastUp, keyB: k}, e.At-x.lastUpAt)
+		}
+
+		x.hasUp = true
+		x.lastUp = k
+		x.lastUpAt = e.At
+	}
+}
+
+// addNGraphs adds the NGraph properties ending in a KeyDown of key k at at.
+func (x *dynamicsExtractor) addNGraphs(k rune, at int) {
+	if x.maxNGraph < minNGraphLength {
+		return
+	}
+
+	if x.opts.MaxGap > 0 && len(x.downs) > 0 && time.Duration(at-x.downAts[len(x.downs)-1])*x.rec.resolution() > x.opts.MaxGap {
+		// Sequences do not span pauses
+
+		x.downs = x.downs[:0]
+		x.downAts = x.downAts[:0]
+	}
+
+	x.downs = append(x.downs, k)
+	x.downAts = append(x.downAts, at)
+
+	if len(x.downs) > x.maxNGraph {
+		// Retain only the keys which may begin a sequence
+
+		copy(x.downs, x.downs[1:])
+		copy(x.downAts, x.downAts[1:])
+
+		x.downs = x.downs[:x.maxNGraph]
+		x.downAts = x.downAts[:x.maxNGraph]
+	}
+
+	for _, n := range x.opts.NGraphs {
+		if n < minNGraphLength || n > len(x.downs) {
+			continue
+		}
+
+		first := len(x.downs) - n
+
+		x.addTiming(extractedProperty{
+			kind: NGraph,
+			keyA: x.downs[first],
+			keyB: k,
+			keys: string(x.downs[first:]),
+		}, at-x.downAts[first])
+	}
+}
+
+// dynamics returns Dynamics holding the average timing of each extracted property with at least MinOccurrences timings.
+func (x *dynamicsExtractor) dynamics() *Dynamics {
+	d := NewDynamics()
+
+	for p, t := range x.timings {
+		if t.count < x.opts.MinOccurrences {
+			continue
+		}
+
+		prop := &DynamicsProperty{
+			Kind:  p.kind,
+			KeyA:  p.keyA,
+			KeyB:  p.keyB,
+			Value: t.mean,
+		}
+
+		if t.stats != nil {
+			prop.Stats = t.stats.stats()
+		}
+
+		if p.kind == NGraph {
+			prop.Keys = []rune(p.keys)
+		}
+
+		d.AddProperty(prop)
+	}
+
+	return d
+}
@@ -0,0 +1,205 @@
+package keyize
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestRecording_Dynamics(t *testing.T) {
+	// Rollover: b is pressed before a is released, and a is released again without a press
+
+	rec := &Recording{
+		Events: []*RecordingEvent{
+			{At: 0, Kind: KeyDown, Subject: 'a'},
+			{At: 60, Kind: KeyDown, Subject: 'b'},
+			{At: 90, Kind: KeyUp, Subject: 'a'},
+			{At: 100, Kind: KeyDown, Subject: 'a'},
+			{At: 130, Kind: KeyUp, Subject: 'b'},
+			{At: 150, Kind: KeyUp, Subject: 'a'},
+			{At: 170, Kind: KeyUp, Subject: 'a'},
+		},
+	}
+
+	props := rec.Dynamics().Properties()
+
+	expected := map[string]float64{
+		"D.a":    70,
+		"D.b":    70,
+		"DD.a.b": 60,
+		"DD.b.a": 40,
+		"UD.a.a": 10,
+		"UU.a.b": 40,
+		"UU.b.a": 20,
+		"UU.a.a": 20,
+		"DU.a.b": 130,
+		"DU.b.a": 90,
+	}
+
+	if len(props) != len(expected) {
+		t.Fatalf("unexpected properties %v", props)
+	}
+
+	for name, v := range expected {
+		if p, ok := props[name]; !ok || p.Value != v {
+			t.Errorf("unexpected %s %v, expected %f", name, p, v)
+		}
+	}
+
+	// Shift is released before the letter, so each 'A' is released as 'a'
+
+	rec, err := ImportKeyizeV1("d[Shift]0dA10u[Shift]20ua30d[Shift]100dA110u[Shift]120ua150")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props = rec.Dynamics().Properties()
+
+	if p := props["D.A"]; p == nil || p.Value != 30 || props["D.a"] != nil || props["UU.[Shift].A"].Value != 20 {
+		t.Fatalf("unexpected properties %v", props)
+	}
+
+	// Without Shift's KeyUp, the release is paired with the press by its Code
+
+	rec = &Recording{
+		Events: []*RecordingEvent{
+			{At: 0, Kind: KeyDown, Subject: 'A', Code: "KeyA"},
+			{At: 50, Kind: KeyUp, Subject: 'a', Code: "KeyA"},
+			{At: 200, Kind: KeyDown, Subject: 'A', Code: "KeyA"},
+			{At: 240, Kind: KeyUp, Subject: 'a', Code: "KeyA"},
+		},
+	}
+
+	if p := rec.Dynamics().Properties()["D.A"]; p == nil || p.Value != 45 {
+		t.Fatalf("unexpected property %v", p)
+	}
+}
+
+func TestRecording_DynamicsWithOptions(t *testing.T) {
+	// "Ab", a pause, then "ab"
+
+	rec := &Recording{
+		Events: []*RecordingEvent{
+			{At: 0, Kind: KeyDown, Subject: KeyShift},
+			{At: 20, Kind: KeyDown, Subject: 'A'},
+			{At: 60, Kind: KeyUp, Subject: KeyShift},
+			{At: 80, Kind: KeyUp, Subject: 'A'},
+			{At: 200, Kind: KeyDown, Subject: 'b'},
+			{At: 280, Kind: KeyUp, Subject: 'b'},
+			{At: 30200, Kind: KeyDown, Subject: 'a'},
+			{At: 30300, Kind: KeyUp, Subject: 'a'},
+			{At: 30400, Kind: KeyDown, Subject: 'b'},
+			{At: 30500, Kind: KeyUp, Subject: 'b'},
+		},
+	}
+
+	props := rec.DynamicsWithOptions(&ExtractOptions{
+		MaxGap:         time.Second,
+		MinOccurrences: 2,
+		Kinds:          []DynamicsPropertyKind{Dwell, DownDown},
+		FoldCase:       true,
+		IgnoreKeys:     []rune{KeyShift},
+	}).Properties()
+
+	expected := map[string]float64{
+		"D.a":    80,
+		"D.b":    90,
+		"DD.a.b": 190,
+	}
+
+	if len(props) != len(expected) {
+		t.Fatalf("unexpected properties %v", props)
+	}
+
+	for name, v := range expected {
+		if p, ok := props[name]; !ok || p.Value != v {
+			t.Errorf("unexpected %s %v, expected %f", name, p, v)
+		}
+	}
+
+	// Trigraphs do not span the pause
+
+	props = rec.DynamicsWithOptions(&ExtractOptions{
+		MaxGap:  time.Second,
+		Kinds:   []DynamicsPropertyKind{NGraph},
+		NGraphs: []int{2, 3},
+	}).Properties()
+
+	if len(props) != 1 || props["DD3.[Shift].A.b"].Value != 200 {
+		t.Fatalf("unexpected NGraph properties %v", props)
+	}
+
+	// nil options are equivalent to Dynamics
+
+	if n := len(rec.DynamicsWithOptions(nil).Properties()); n != len(rec.Dynamics().Properties()) {
+		t.Fatalf("unexpected property count %d", n)
+	}
+}
+
+// hourRecording creates a Recording of an hour of free-text typing with rollover, at microsecond resolution.
+func hourRecording() *Recording {
+	rng := rand.New(rand.NewSource(1))
+
+	rec := &Recording{
+		Resolution: time.Microsecond,
+	}
+
+	const keys = "abcdefghijklmnopqrstuvwxyz ,."
+
+	// pending holds key ups which are yet to be added, as rollover allows them to follow later key downs
+	var pending []*RecordingEvent
+
+	hour := int(time.Hour / time.Microsecond)
+
+	for at := 0; at < hour; at += 80000 + rng.Intn(200000) {
+		for len(pending) > 0 && pending[0].At <= at {
+			rec.Events = append(rec.Events, pending[0])
+			pending = pending[1:]
+		}
+
+		k := rune(keys[rng.Intn(len(keys))])
+
+		rec.Events = append(rec.Events, &RecordingEvent{At: at, Kind: KeyDown, Subject: k})
+
+		up := &RecordingEvent{At: at + 50000 + rng.Intn(100000), Kind: KeyUp, Subject: k}
+
+		// Keep pending ordered by At
+
+		i := len(pending)
+
+		for i > 0 && pending[i-1].At > up.At {
+			i--
+		}
+
+		pending = append(pending, nil)
+		copy(pending[i+1:], pending[i:])
+		pending[i] = up
+	}
+
+	rec.Events = append(rec.Events, pending...)
+
+	return rec
+}
+
+func BenchmarkRecording_Dynamics(b *testing.B) {
+	rec := hourRecording()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rec.Dynamics()
+	}
+}
+
+func BenchmarkRecording_PhysicalDynamics(b *testing.B) {
+	rec := hourRecording()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rec.PhysicalDynamics()
+	}
+}
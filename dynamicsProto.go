@@ -0,0 +1,371 @@
+package keyize
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Protocol Buffers wire types used by the Dynamics encoding.
+// See https://developers.google.com/protocol-buffers/docs/encoding
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// Field numbers from dynamics.proto
+const (
+	protoDynamicsProperties = 1
+
+	protoPropertyKind  = 1
+	protoPropertyKeyA  = 2
+	protoPropertyKeyB  = 3
+	protoPropertyValue = 4
+	protoPropertyKeys  = 5
+	protoPropertyStats = 6
+
+	protoStatsCount  = 1
+	protoStatsStdDev = 2
+	protoStatsMedian = 3
+	protoStatsMin    = 4
+	protoStatsMax    = 5
+	protoStatsMAD    = 6
+)
+
+func appendProtoVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(buf[:], v)
+
+	return append(b, buf[:n]...)
+}
+
+func appendProtoTag(b []byte, field int, wireType int) []byte {
+	return appendProtoVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendProtoFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+
+	binary.LittleEndian.PutUint64(buf[:], v)
+
+	return append(b, buf[:]...)
+}
+
+// protoField is a single field read from a Protocol Buffers message.
+type protoField struct {
+	number   int
+	wireType int
+
+	// varint holds the value of varint, fixed64 and fixed32 fields
+	varint uint64
+
+	// bytes holds the value of length-delimited fields
+	bytes []byte
+}
+
+// consumeProtoField reads the field at the start of b and returns it with the count of bytes it occupied.
+func consumeProtoField(b []byte) (protoField, int, error) {
+	var f protoField
+
+	tag, pos := binary.Uvarint(b)
+
+	if pos <= 0 {
+		return f, 0, errors.New("invalid protobuf field tag")
+	}
+
+	f.number = int(tag >> 3)
+	f.wireType = int(tag & 7)
+
+	if f.number <= 0 {
+		return f, 0, errors.New("invalid protobuf field number " + strconv.Itoa(f.number))
+	}
+
+	switch f.wireType {
+	case protoVarint:
+		v, n := binary.Uvarint(b[pos:])
+
+		if n <= 0 {
+			return f, 0, errors.New("invalid protobuf varint")
+		}
+
+		f.varint = v
+		pos += n
+	case protoFixed64:
+		if len(b)-pos < 8 {
+			return f, 0, errors.New("truncated protobuf fixed64")
+		}
+
+		f.varint = binary.LittleEndian.Uint64(b[pos:])
+		pos += 8
+	case protoFixed32:
+		if len(b)-pos < 4 {
+			return f, 0, errors.New("truncated protobuf fixed32")
+		}
+
+		f.varint = uint64(binary.LittleEndian.Uint32(b[pos:]))
+		pos += 4
+	case protoBytes:
+		l, n := binary.Uvarint(b[pos:])
+
+		if n <= 0 || l > uint64(len(b)-pos-n) {
+			return f, 0, errors.New("invalid protobuf length")
+		}
+
+		pos += n
+		f.bytes = b[pos : pos+int(l)]
+		pos += int(l)
+	default:
+		return f, 0, errors.New("unsupported protobuf wire type " + strconv.Itoa(f.wireType))
+	}
+
+	return f, pos, nil
+}
+
+func appendDynamicsPropertyProto(b []byte, p *DynamicsProperty) []byte {
+	// Zero values are omitted, as is done by proto3 encoders
+
+	if p.Kind != Dwell {
+		b = appendProtoTag(b, protoPropertyKind, protoVarint)
+		b = appendProtoVarint(b, uint64(p.Kind))
+	}
+
+	if p.KeyA != 0 {
+		b = appendProtoTag(b, protoPropertyKeyA, protoVarint)
+		b = appendProtoVarint(b, uint64(p.KeyA))
+	}
+
+	if p.KeyB != 0 && p.Kind != Dwell {
+		b = appendProtoTag(b, protoPropertyKeyB, protoVarint)
+		b = appendProtoVarint(b, uint64(p.KeyB))
+	}
+
+	if p.Value != 0 {
+		b = appendProtoTag(b, protoPropertyValue, protoFixed64)
+		b = appendProtoFixed64(b, math.Float64bits(p.Value))
+	}
+
+	if len(p.Keys) > 0 && p.Kind == NGraph {
+		// Packed, as is done by proto3 encoders for repeated scalars
+
+		var keys []byte
+
+		for _, k := range p.Keys {
+			keys = appendProtoVarint(keys, uint64(k))
+		}
+
+		b = appendProtoTag(b, protoPropertyKeys, protoBytes)
+		b = appendProtoVarint(b, uint64(len(keys)))
+		b = append(b, keys...)
+	}
+
+	if p.Stats != nil {
+		stats := appendPropertyStatsProto(nil, p.Stats)
+
+		b = appendProtoTag(b, protoPropertyStats, protoBytes)
+		b = appendProtoVarint(b, uint64(len(stats)))
+		b = append(b, stats...)
+	}
+
+	return b
+}
+
+func appendPropertyStatsProto(b []byte, s *PropertyStats) []byte {
+	if s.Count != 0 {
+		b = appendProtoTag(b, protoStatsCount, protoVarint)
+		b = appendProtoVarint(b, uint64(s.Count))
+	}
+
+	b = appendProtoDouble(b, protoStatsStdDev, s.StdDev)
+	b = appendProtoDouble(b, protoStatsMedian, s.Median)
+	b = appendProtoDouble(b, protoStatsMin, s.Min)
+	b = appendProtoDouble(b, protoStatsMax, s.Max)
+	b = appendProtoDouble(b, protoStatsMAD, s.MeanAbsDev)
+
+	return b
+}
+
+// appendProtoDouble appends double field v, unless it is zero.
+func appendProtoDouble(b []byte, field int, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	b = appendProtoTag(b, field, protoFixed64)
+
+	return appendProtoFixed64(b, math.Float64bits(v))
+}
+
+func unmarshalPropertyStatsProto(b []byte) (*PropertyStats, error) {
+	s := &PropertyStats{}
+
+	for len(b) > 0 {
+		f, n, err := consumeProtoField(b)
+
+		if err != nil {
+			return nil, err
+		}
+
+		b = b[n:]
+
+		switch {
+		case f.number == protoStatsCount && f.wireType == protoVarint:
+			if f.varint > uint64(maxInt) {
+				return nil, errors.New("invalid PropertyStats count")
+			}
+
+			s.Count = int(f.varint)
+		case f.number == protoStatsStdDev && f.wireType == protoFixed64:
+			s.StdDev = math.Float64frombits(f.varint)
+		case f.number == protoStatsMedian && f.wireType == protoFixed64:
+			s.Median = math.Float64frombits(f.varint)
+		case f.number == protoStatsMin && f.wireType == protoFixed64:
+			s.Min = math.Float64frombits(f.varint)
+		case f.number == protoStatsMax && f.wireType == protoFixed64:
+			s.Max = math.Float64frombits(f.varint)
+		case f.number == protoStatsMAD && f.wireType == protoFixed64:
+			s.MeanAbsDev = math.Float64frombits(f.varint)
+		}
+	}
+
+	return s, nil
+}
+
+func unmarshalDynamicsPropertyProto(b []byte) (*DynamicsProperty, error) {
+	p := &DynamicsProperty{}
+
+	for len(b) > 0 {
+		f, n, err := consumeProtoField(b)
+
+		if err != nil {
+			return nil, err
+		}
+
+		b = b[n:]
+
+		switch {
+		case f.number == protoPropertyKind && f.wireType == protoVarint:
+			p.Kind = DynamicsPropertyKind(f.varint)
+		case f.number == protoPropertyKeyA && f.wireType == protoVarint:
+			p.KeyA = rune(uint32(f.varint))
+		case f.number == protoPropertyKeyB && f.wireType == protoVarint:
+			p.KeyB = rune(uint32(f.varint))
+		case f.number == protoPropertyValue && f.wireType == protoFixed64:
+			p.Value = math.Float64frombits(f.varint)
+		case f.number == protoPropertyKeys && f.wireType == protoVarint:
+			p.Keys = append(p.Keys, rune(uint32(f.varint)))
+		case f.number == protoPropertyKeys && f.wireType == protoBytes:
+			for packed := f.bytes; len(packed) > 0; {
+				k, n := binary.Uvarint(packed)
+
+				if n <= 0 {
+					return nil, errors.New("invalid protobuf packed varint")
+				}
+
+				p.Keys = append(p.Keys, rune(uint32(k)))
+				packed = packed[n:]
+			}
+		case f.number == protoPropertyStats && f.wireType == protoBytes:
+			if p.Stats, err = unmarshalPropertyStatsProto(f.bytes); err != nil {
+				return nil, err
+			}
+		}
+
+		// Unknown fields are skipped for forwards compatibility
+	}
+
+	if _, ok := defaultDynamicsPropertyKindScaleMap[p.Kind]; !ok {
+		return nil, errors.New("invalid DynamicsPropertyKind " + strconv.Itoa(int(p.Kind)))
+	}
+
+	if !utf8.ValidRune(p.KeyA) || (p.Kind != Dwell && !utf8.ValidRune(p.KeyB)) {
+		return nil, errors.New("invalid DynamicsProperty key")
+	}
+
+	if p.Kind == NGraph {
+		if len(p.Keys) < minNGraphLength {
+			return nil, errors.New("invalid NGraph DynamicsProperty with " + strconv.Itoa(len(p.Keys)) + " keys")
+		}
+
+		for _, k := range p.Keys {
+			if !utf8.ValidRune(k) {
+				return nil, errors.New("invalid DynamicsProperty key")
+			}
+		}
+
+		p.KeyA = p.Keys[0]
+		p.KeyB = p.Keys[len(p.Keys)-1]
+	} else {
+		p.Keys = nil
+	}
+
+	if p.Kind == Dwell {
+		p.KeyB = '\x00'
+	}
+
+	return p, nil
+}
+
+// MarshalBinary encodes Dynamics d using the Protocol Buffers wire format described by the Dynamics message in dynamics.proto.
+//
+// Properties are encoded in order of name, so equal Dynamics produce equal encodings.
+func (d *Dynamics) MarshalBinary() ([]byte, error) {
+	names := make([]string, 0, len(d.properties))
+
+	for name := range d.properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b []byte
+	var propBuf []byte
+
+	for _, name := range names {
+		propBuf = appendDynamicsPropertyProto(propBuf[:0], d.properties[name])
+
+		b = appendProtoTag(b, protoDynamicsProperties, protoBytes)
+		b = appendProtoVarint(b, uint64(len(propBuf)))
+		b = append(b, propBuf...)
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary replaces the properties of Dynamics d with those decoded from data,
+// which must use the Protocol Buffers wire format described by the Dynamics message in dynamics.proto.
+func (d *Dynamics) UnmarshalBinary(data []byte) error {
+	properties := map[string]*DynamicsProperty{}
+
+	for len(data) > 0 {
+		f, n, err := consumeProtoField(data)
+
+		if err != nil {
+			return err
+		}
+
+		data = data[n:]
+
+		if f.number != protoDynamicsProperties || f.wireType != protoBytes {
+			// Unknown field
+			continue
+		}
+
+		p, err := unmarshalDynamicsPropertyProto(f.bytes)
+
+		if err != nil {
+			return err
+		}
+
+		properties[p.Name()] = p
+	}
+
+	d.properties = properties
+
+	return nil
+}
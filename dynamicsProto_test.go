@@ -0,0 +1,71 @@
+package keyize
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDynamics_MarshalBinary(t *testing.T) {
+	d := NewDynamics()
+
+	d.AddPropertyByName("DD.a.b", 1.5)
+
+	data, err := d.MarshalBinary()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expected bytes as produced by a standard protobuf encoder for dynamics.proto
+
+	expected := []byte{
+		0x0a, 0x0f,
+		0x08, 0x01,
+		0x10, 'a',
+		0x18, 'b',
+		0x21, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f,
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("unexpected encoding % x", data)
+	}
+
+	d.AddPropertyByName("D.H", 93.25)
+	d.AddPropertyByName("UD.é.\n", -4)
+	d.AddPropertyByName("UU.x.y", 7)
+	d.AddPropertyByName("DU.[Shift].Y", 180)
+	d.AddPropertyByName("DD3.t.h.e", 260)
+
+	d.Properties()["D.H"].Stats = &PropertyStats{Count: 4, StdDev: 12.5, Median: 90, Min: 80, Max: 120}
+
+	data, err = d.MarshalBinary()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown fields must be skipped
+
+	data = append(data, 0x7a, 0x01, 0x00)
+
+	decoded := NewDynamics()
+
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	props := decoded.Properties()
+
+	if len(props) != 6 || props["DD3.t.h.e"].Value != 260 || string(props["DD3.t.h.e"].Keys) != "the" || props["DD.a.b"].Value != 1.5 || props["D.H"].Value != 93.25 || props["UD.é.\n"].Value != -4 ||
+		props["UU.x.y"].Value != 7 || props["DU.[Shift].Y"].Value != 180 {
+		t.Fatal("decoded Dynamics has incorrect properties")
+	}
+
+	if s := props["D.H"].Stats; s == nil || *s != (PropertyStats{Count: 4, StdDev: 12.5, Median: 90, Min: 80, Max: 120}) || props["DD.a.b"].Stats != nil {
+		t.Fatalf("decoded Dynamics has incorrect stats %+v", s)
+	}
+
+	if err := decoded.UnmarshalBinary([]byte{0x0a, 0x02, 0x08, 0x63}); err == nil {
+		t.Fatal("expected error for unknown kind")
+	}
+}
@@ -1,6 +1,7 @@
 package keyize
 
 import (
+	"math"
 	"testing"
 )
 
@@ -30,6 +31,10 @@ func TestAvgDynamics(t *testing.T) {
 
 		t.Fatal("Incorrect average property values")
 	}
+
+	if s := avgProps["DD.B.C"].Stats; s == nil || s.Count != 2 || s.Median != 3 || s.Min != 2 || s.Max != 4 {
+		t.Fatalf("Incorrect average property stats %+v", s)
+	}
 }
 
 func TestDynamics_AddPropertyByName(t *testing.T) {
@@ -38,9 +43,41 @@ func TestDynamics_AddPropertyByName(t *testing.T) {
 	d.AddPropertyByName("D.H", 5)
 	d.AddPropertyByName("DD.j.W", 4)
 	d.AddPropertyByName("UD.o.E", 3)
+	d.AddPropertyByName("UU.o.E", 2)
+	d.AddPropertyByName("DU.o.E", 1)
 
 	props := d.Properties()
 
+	if props["UU.o.E"].Kind != UpUp || props["DU.o.E"].Kind != DownUp || props["DU.o.E"].Value != 1 {
+		t.Fatal("UpUp / DownUp properties have incorrect kinds or values")
+	}
+
+	// NGraph names, including keys which are '.' or brackets
+
+	if err := d.AddPropertyByName("DD4.[Shift].T.{KeyH}..", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.AddPropertyByName("DD3.[.].x", 11); err != nil {
+		t.Fatal(err)
+	}
+
+	ngraph := props["DD4.[Shift].T.{KeyH}.."]
+
+	if ngraph == nil || ngraph.Kind != NGraph || string(ngraph.Keys) != string([]rune{KeyShift, 'T', 0xF001A, '.'}) || ngraph.KeyA != KeyShift || ngraph.KeyB != '.' {
+		t.Fatalf("NGraph property has incorrect keys %+v", ngraph)
+	}
+
+	if props["DD3.[.].x"] == nil || string(props["DD3.[.].x"].Keys) != "[]x" {
+		t.Fatal("NGraph property with bracket keys was not added")
+	}
+
+	for _, name := range []string{"DD2.a.b", "DD3.a.b", "DD3.a.b.c.d", "DD3.a.bc"} {
+		if _, err := ParseDynamicsPropertyName(name); err == nil {
+			t.Errorf("expected error parsing %q", name)
+		}
+	}
+
 	if props["D.H"].Value != 5 || props["DD.j.W"].Value != 4 || props["UD.o.E"].Value != 3 {
 		t.Fatal("Not all properties exist / some may have incorrect values")
 	} else {
@@ -136,3 +173,46 @@ func TestDynamics_GetProportionSharedProperties(t *testing.T) {
 		t.Errorf("bad Left %f", v)
 	}
 }
+
+func TestDynamics_ScaledManhattanDist(t *testing.T) {
+	var sessions []*Dynamics
+
+	for _, v := range []float64{90, 100, 110} {
+		d := NewDynamics()
+
+		d.AddPropertyByName("DD.a.b", v)
+		d.AddPropertyByName("D.a", v)
+
+		sessions = append(sessions, d)
+	}
+
+	template := AvgDynamics(sessions)
+
+	// The spread of D.a is unknown
+
+	template.Properties()["D.a"].Stats = nil
+
+	sample := NewDynamics()
+
+	sample.AddPropertyByName("DD.a.b", 120)
+	sample.AddPropertyByName("D.a", 120)
+	sample.AddPropertyByName("UD.a.b", 10)
+
+	// DD.a.b has a mean absolute deviation of 20/3, and D.a is scaled by kind
+
+	expected := 20/(20/3.0) + 20*defaultDynamicsPropertyKindScaleMap[Dwell]
+
+	if dist := template.ScaledManhattanDist(sample, nil); math.Abs(dist-expected) > 1e-9 {
+		t.Fatalf("unexpected distance %f, expected %f", dist, expected)
+	}
+
+	// A property which did not vary is also scaled by kind
+
+	template.Properties()["DD.a.b"].Stats = &PropertyStats{}
+
+	expected = 20*0.5 + 20*defaultDynamicsPropertyKindScaleMap[Dwell]
+
+	if dist := template.ScaledManhattanDist(sample, DynamicsPropertyKindScaleMap{DownDown: 0.5}); math.Abs(dist-expected) > 1e-9 {
+		t.Fatalf("unexpected distance %f, expected %f", dist, expected)
+	}
+}
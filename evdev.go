@@ -0,0 +1,298 @@
+package keyize
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Linux input event types and EV_KEY values, from linux/input-event-codes.h
+const (
+	evdevEvKey = 0x01
+
+	evdevRelease = 0
+	evdevPress   = 1
+	evdevRepeat  = 2
+)
+
+// Linux keycodes of the shift keys
+const (
+	evdevKeyLeftShift  = 42
+	evdevKeyRightShift = 54
+)
+
+// EvdevKeymap maps Linux input keycodes (KEY_* values from linux/input-event-codes.h) to subject runes.
+type EvdevKeymap map[uint16]rune
+
+// clone returns a copy of EvdevKeymap m.
+func (m EvdevKeymap) clone() EvdevKeymap {
+	c := make(EvdevKeymap, len(m))
+
+	for code, r := range m {
+		c[code] = r
+	}
+
+	return c
+}
+
+// DefaultEvdevKeymap maps the keycodes of a US QWERTY keyboard to the runes or named keys they produce.
+var DefaultEvdevKeymap = EvdevKeymap{
+	1: KeyEscape, 2: '1', 3: '2', 4: '3', 5: '4', 6: '5', 7: '6', 8: '7', 9: '8', 10: '9', 11: '0', 12: '-', 13: '=', 14: KeyBackspace,
+	15: KeyTab, 16: 'q', 17: 'w', 18: 'e', 19: 'r', 20: 't', 21: 'y', 22: 'u', 23: 'i', 24: 'o', 25: 'p', 26: '[', 27: ']', 28: KeyEnter,
+	29: KeyControl, 30: 'a', 31: 's', 32: 'd', 33: 'f', 34: 'g', 35: 'h', 36: 'j', 37: 'k', 38: 'l', 39: ';', 40: '\'', 41: '`',
+	42: KeyShift, 43: '\\', 44: 'z', 45: 'x', 46: 'c', 47: 'v', 48: 'b', 49: 'n', 50: 'm', 51: ',', 52: '.', 53: '/', 54: KeyShift,
+	55: '*', 56: KeyAlt, 57: ' ', 58: KeyCapsLock, 59: KeyF1, 60: KeyF2, 61: KeyF3, 62: KeyF4, 63: KeyF5, 64: KeyF6, 65: KeyF7,
+	66: KeyF8, 67: KeyF9, 68: KeyF10, 69: KeyNumLock, 70: KeyScrollLock, 71: '7', 72: '8', 73: '9', 74: '-', 75: '4', 76: '5',
+	77: '6', 78: '+', 79: '1', 80: '2', 81: '3', 82: '0', 83: '.', 87: KeyF11, 88: KeyF12, 96: KeyEnter, 97: KeyControl, 98: '/',
+	99: KeyPrintScreen, 100: KeyAlt, 102: KeyHome, 103: KeyArrowUp, 104: KeyPageUp, 105: KeyArrowLeft, 106: KeyArrowRight,
+	107: KeyEnd, 108: KeyArrowDown, 109: KeyPageDown, 110: KeyInsert, 111: KeyDelete, 119: KeyPause, 125: KeyMeta, 126: KeyMeta,
+	127: KeyContextMenu,
+}
+
+// DefaultEvdevShiftKeymap maps the keycodes of a US QWERTY keyboard to the runes they produce while shift is held.
+var DefaultEvdevShiftKeymap = EvdevKeymap{
+	2: '!', 3: '@', 4: '#', 5: '$', 6: '%', 7: '^', 8: '&', 9: '*', 10: '(', 11: ')', 12: '_', 13: '+',
+	16: 'Q', 17: 'W', 18: 'E', 19: 'R', 20: 'T', 21: 'Y', 22: 'U', 23: 'I', 24: 'O', 25: 'P', 26: '{', 27: '}',
+	30: 'A', 31: 'S', 32: 'D', 33: 'F', 34: 'G', 35: 'H', 36: 'J', 37: 'K', 38: 'L', 39: ':', 40: '"', 41: '~',
+	43: '|', 44: 'Z', 45: 'X', 46: 'C', 47: 'V', 48: 'B', 49: 'N', 50: 'M', 51: '<', 52: '>', 53: '?',
+}
+
+// evdevKeyCodes maps Linux input keycodes to W3C UI Events code values, which are independent of keyboard layout.
+var evdevKeyCodes = map[uint16]string{
+	1: "Escape", 2: "Digit1", 3: "Digit2", 4: "Digit3", 5: "Digit4", 6: "Digit5", 7: "Digit6", 8: "Digit7", 9: "Digit8",
+	10: "Digit9", 11: "Digit0", 12: "Minus", 13: "Equal", 14: "Backspace", 15: "Tab", 16: "KeyQ", 17: "KeyW", 18: "KeyE",
+	19: "KeyR", 20: "KeyT", 21: "KeyY", 22: "KeyU", 23: "KeyI", 24: "KeyO", 25: "KeyP", 26: "BracketLeft",
+	27: "BracketRight", 28: "Enter", 29: "ControlLeft", 30: "KeyA", 31: "KeyS", 32: "KeyD", 33: "KeyF", 34: "KeyG",
+	35: "KeyH", 36: "KeyJ", 37: "KeyK", 38: "KeyL", 39: "Semicolon", 40: "Quote", 41: "Backquote", 42: "ShiftLeft",
+	43: "Backslash", 44: "KeyZ", 45: "KeyX", 46: "KeyC", 47: "KeyV", 48: "KeyB", 49: "KeyN", 50: "KeyM", 51: "Comma",
+	52: "Period", 53: "Slash", 54: "ShiftRight", 55: "NumpadMultiply", 56: "AltLeft", 57: "Space", 58: "CapsLock",
+	59: "F1", 60: "F2", 61: "F3", 62: "F4", 63: "F5", 64: "F6", 65: "F7", 66: "F8", 67: "F9", 68: "F10", 69: "NumLock",
+	70: "ScrollLock", 71: "Numpad7", 72: "Numpad8", 73: "Numpad9", 74: "NumpadSubtract", 75: "Numpad4", 76: "Numpad5",
+	77: "Numpad6", 78: "NumpadAdd", 79: "Numpad1", 80: "Numpad2", 81: "Numpad3", 82: "Numpad0", 83: "NumpadDecimal",
+	86: "IntlBackslash", 87: "F11", 88: "F12", 89: "IntlRo", 96: "NumpadEnter", 97: "ControlRight", 98: "NumpadDivide",
+	99: "PrintScreen", 100: "AltRight", 102: "Home", 103: "ArrowUp", 104: "PageUp", 105: "ArrowLeft", 106: "ArrowRight",
+	107: "End", 108: "ArrowDown", 109: "PageDown", 110: "Insert", 111: "Delete", 117: "NumpadEqual", 119: "Pause",
+	121: "NumpadComma", 124: "IntlYen", 125: "MetaLeft", 126: "MetaRight", 127: "ContextMenu", 138: "Help",
+}
+
+// EvdevDecoder reads RecordingEvents from a stream of Linux input_event structs,
+// such as a capture of /dev/input/event*.
+//
+// Key presses and releases become KeyDown and KeyUp events. Auto-repeats become additional KeyDown events
+// with Repeat set, as they are in browsers. At values are in microseconds, relative to the first input_event.
+// The physical key of each event is set as its Code.
+type EvdevDecoder struct {
+	// Keymap maps keycodes to subjects. Events for keycodes absent from Keymap are skipped.
+	Keymap EvdevKeymap
+
+	// ShiftKeymap, if not nil, is consulted before Keymap while a shift key is held, for keycodes present in Keymap.
+	ShiftKeymap EvdevKeymap
+
+	// ByteOrder is the byte order of the capture. It defaults to binary.LittleEndian.
+	ByteOrder binary.ByteOrder
+
+	// Time32 indicates that the capture was made on a platform with 32-bit time fields,
+	// where input_event occupies 16 rather than 24 bytes.
+	Time32 bool
+
+	r   io.Reader
+	buf [24]byte
+
+	started bool
+	start   int64
+	lastAt  int64
+
+	// shiftsHeld is the count of shift keys currently held
+	shiftsHeld int
+
+	// heldSubjects holds the subject chosen when each held key was pressed, so its release has the same subject
+	heldSubjects map[uint16]rune
+
+	// err is the first error encountered. Once set, it is returned by every call to Next.
+	err error
+}
+
+// NewEvdevDecoder returns a new EvdevDecoder which reads from r using copies of the default keymaps,
+// which may be modified without affecting other decoders.
+func NewEvdevDecoder(r io.Reader) *EvdevDecoder {
+	return &EvdevDecoder{
+		Keymap:       DefaultEvdevKeymap.clone(),
+		ShiftKeymap:  DefaultEvdevShiftKeymap.clone(),
+		ByteOrder:    binary.LittleEndian,
+		r:            r,
+		heldSubjects: map[uint16]rune{},
+	}
+}
+
+// Next decodes and returns the next RecordingEvent.
+//
+// It returns io.EOF once the input has been exhausted.
+func (dec *EvdevDecoder) Next() (*RecordingEvent, error) {
+	if dec.err != nil {
+		return nil, dec.err
+	}
+
+	e, err := dec.next()
+
+	if err != nil {
+		dec.err = err
+
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (dec *EvdevDecoder) next() (*RecordingEvent, error) {
+	size := 24
+
+	if dec.Time32 {
+		size = 16
+	}
+
+	order := dec.ByteOrder
+
+	if order == nil {
+		order = binary.LittleEndian
+	}
+
+	for {
+		buf := dec.buf[:size]
+
+		if _, err := io.ReadFull(dec.r, buf); err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated input_event")
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Decode struct input_event
+
+		var sec, usec int64
+
+		if dec.Time32 {
+			sec = int64(int32(order.Uint32(buf[0:])))
+			usec = int64(int32(order.Uint32(buf[4:])))
+		} else {
+			sec = int64(order.Uint64(buf[0:]))
+			usec = int64(order.Uint64(buf[8:]))
+		}
+
+		evType := order.Uint16(buf[size-8:])
+		code := order.Uint16(buf[size-6:])
+		value := int32(order.Uint32(buf[size-4:]))
+
+		t := sec*int64(time.Second/time.Microsecond) + usec
+
+		if !dec.started {
+			dec.started = true
+			dec.start = t
+		}
+
+		if evType != evdevEvKey {
+			continue
+		}
+
+		at := t - dec.start
+
+		if at < dec.lastAt {
+			return nil, errors.New("invalid input_event time " + strconv.FormatInt(at, 10) + "us is less than previous")
+		}
+
+		dec.lastAt = at
+
+		var kind RawEventKind
+		var subject rune
+
+		switch value {
+		case evdevPress, evdevRepeat:
+			kind = KeyDown
+
+			if value == evdevPress && (code == evdevKeyLeftShift || code == evdevKeyRightShift) {
+				dec.shiftsHeld++
+			}
+
+			var ok bool
+
+			if subject, ok = dec.heldSubjects[code]; !ok {
+				subject, ok = dec.subject(code)
+
+				if !ok {
+					continue
+				}
+
+				dec.heldSubjects[code] = subject
+			}
+		case evdevRelease:
+			kind = KeyUp
+
+			if (code == evdevKeyLeftShift || code == evdevKeyRightShift) && dec.shiftsHeld > 0 {
+				dec.shiftsHeld--
+			}
+
+			var ok bool
+
+			if subject, ok = dec.heldSubjects[code]; ok {
+				delete(dec.heldSubjects, code)
+			} else if subject, ok = dec.subject(code); !ok {
+				continue
+			}
+		default:
+			continue
+		}
+
+		return &RecordingEvent{
+			At:      int(at),
+			Kind:    kind,
+			Subject: subject,
+			Code:    evdevKeyCodes[code],
+			Repeat:  value == evdevRepeat,
+		}, nil
+	}
+}
+
+// subject returns the subject for keycode code with respect to the current shift state.
+func (dec *EvdevDecoder) subject(code uint16) (rune, bool) {
+	r, ok := dec.Keymap[code]
+
+	if !ok {
+		return 0, false
+	}
+
+	if dec.shiftsHeld > 0 && dec.ShiftKeymap != nil {
+		if shifted, ok := dec.ShiftKeymap[code]; ok {
+			return shifted, true
+		}
+	}
+
+	return r, true
+}
+
+// ImportEvdev imports a capture of Linux input_event structs from r using the default keymaps.
+// The Recording has microsecond Resolution.
+func ImportEvdev(r io.Reader) (*Recording, error) {
+	return NewEvdevDecoder(r).Recording()
+}
+
+// Recording decodes all remaining events into a Recording with microsecond Resolution.
+func (dec *EvdevDecoder) Recording() (*Recording, error) {
+	rec := &Recording{
+		Events:     []*RecordingEvent{},
+		Resolution: time.Microsecond,
+	}
+
+	for {
+		e, err := dec.Next()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		rec.Events = append(rec.Events, e)
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,118 @@
+package keyize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+// writeEvdevEvent appends a 64-bit struct input_event to b.
+func writeEvdevEvent(b *bytes.Buffer, at time.Duration, evType uint16, code uint16, value int32) {
+	binary.Write(b, binary.LittleEndian, struct {
+		Sec   int64
+		Usec  int64
+		Type  uint16
+		Code  uint16
+		Value int32
+	}{
+		Sec:   int64(at / time.Second),
+		Usec:  int64(at % time.Second / time.Microsecond),
+		Type:  evType,
+		Code:  code,
+		Value: value,
+	})
+}
+
+func TestImportEvdev(t *testing.T) {
+	b := &bytes.Buffer{}
+
+	base := 1600000000 * time.Second
+
+	// Shift+h held with an auto-repeat, shift released before h, then a keypad 1. EV_SYN and EV_MSC events are interleaved.
+
+	writeEvdevEvent(b, base, 0x04, 4, 42)
+	writeEvdevEvent(b, base, 0x01, 42, 1)
+	writeEvdevEvent(b, base, 0x00, 0, 0)
+	writeEvdevEvent(b, base+100250*time.Microsecond, 0x01, 35, 1)
+	writeEvdevEvent(b, base+600*time.Millisecond, 0x01, 35, 2)
+	writeEvdevEvent(b, base+700*time.Millisecond, 0x01, 42, 0)
+	writeEvdevEvent(b, base+750*time.Millisecond, 0x01, 35, 0)
+	writeEvdevEvent(b, base+time.Second, 0x01, 79, 1)
+	writeEvdevEvent(b, base+time.Second+80*time.Millisecond, 0x01, 79, 0)
+
+	rec, err := ImportEvdev(bytes.NewReader(b.Bytes()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Resolution != time.Microsecond {
+		t.Fatalf("unexpected resolution %v", rec.Resolution)
+	}
+
+	expected := []RecordingEvent{
+		{At: 0, Kind: KeyDown, Subject: KeyShift, Code: "ShiftLeft"},
+		{At: 100250, Kind: KeyDown, Subject: 'H', Code: "KeyH"},
+		{At: 600000, Kind: KeyDown, Subject: 'H', Code: "KeyH", Repeat: true},
+		{At: 700000, Kind: KeyUp, Subject: KeyShift, Code: "ShiftLeft"},
+		{At: 750000, Kind: KeyUp, Subject: 'H', Code: "KeyH"},
+		{At: 1000000, Kind: KeyDown, Subject: '1', Code: "Numpad1"},
+		{At: 1080000, Kind: KeyUp, Subject: '1', Code: "Numpad1"},
+	}
+
+	if len(rec.Events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(rec.Events))
+	}
+
+	for i, e := range rec.Events {
+		if *e != expected[i] {
+			t.Errorf("event %d: %+v != %+v", i, *e, expected[i])
+		}
+	}
+
+	// Dwell must be computed in milliseconds
+
+	if v := rec.Dynamics().Properties()["D.1"].Value; v != 80 {
+		t.Errorf("bad D.1 value %f", v)
+	}
+
+	// A custom keymap and truncated input
+
+	dec := NewEvdevDecoder(bytes.NewReader(b.Bytes()[:b.Len()-1]))
+	dec.Keymap = EvdevKeymap{79: 'x'}
+	dec.ShiftKeymap = nil
+
+	e, err := dec.Next()
+
+	if err != nil || e.Subject != 'x' {
+		t.Fatalf("unexpected event %+v (%v)", e, err)
+	}
+
+	if _, err := dec.Next(); err == nil || err == io.EOF {
+		t.Fatal("expected error for truncated input_event")
+	}
+
+	// Keycodes absent from a custom Keymap are skipped while shift is held, and the defaults are not modified
+
+	dec = NewEvdevDecoder(bytes.NewReader(b.Bytes()))
+	dec.Keymap[35] = 'x'
+	delete(dec.Keymap, 79)
+
+	if DefaultEvdevKeymap[35] != 'h' || DefaultEvdevKeymap[79] != '1' {
+		t.Fatal("default keymap was modified")
+	}
+
+	dec.Keymap = EvdevKeymap{79: 'x'}
+
+	rec, err = dec.Recording()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.Events) != 2 || rec.Events[0].Subject != 'x' {
+		t.Fatalf("unexpected events %v", rec.Events)
+	}
+}
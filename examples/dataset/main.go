@@ -1,42 +1,17 @@
 package main
 
 import (
-	"encoding/csv"
 	"flag"
 	"fmt"
 	"github.com/KeyizeBiometry/keyize"
+	"github.com/KeyizeBiometry/keyize/datasets"
 	"os"
-	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
-	"unicode/utf8"
 )
 
 var maxGroupSize *int = flag.Int("maxClosedSetGroupSize", 10, "Max group size for closed set identification")
 
-var firstSegKindMap = map[string]keyize.DynamicsPropertyKind{
-	"H":  keyize.Dwell,
-	"DD": keyize.DownDown,
-	"UD": keyize.UpDown,
-}
-
-var convRuneMap = map[string]rune{
-	"Shift":  '\x0F',
-	"period": '.',
-	"five":   '5',
-	"Return": '\n',
-}
-
-func convRune(s string) rune {
-	if len(s) == 1 {
-		r, _ := utf8.DecodeRuneInString(s)
-		return r
-	} else {
-		return convRuneMap[s]
-	}
-}
-
 type subject struct {
 	name       string
 	sessions   []*keyize.Dynamics
@@ -53,7 +28,7 @@ func main() {
 	f, err := os.Open("DSL-StrongPasswordData.csv")
 
 	if err != nil {
-		if err == os.ErrNotExist {
+		if os.IsNotExist(err) {
 			fmt.Println("Tip: The DSL-StrongPasswordData.csv dataset may be downloaded from https://www.cs.cmu.edu/~keystroke/")
 		}
 
@@ -66,89 +41,18 @@ func main() {
 
 	fmt.Println("Loading data...")
 
-	r := csv.NewReader(f)
-
-	var fieldOrder []string
-
-	for i := 0; ; i++ {
-		record, err := r.Read()
-
-		if err != nil {
-			break
-		}
-
-		if i == 0 {
-			// Get field order from first record
-
-			fieldOrder = record
-			continue
-		}
-
-		s := keyize.NewDynamics()
-		var curSubj string
-
-		for i2, v := range record {
-			fieldName := fieldOrder[i2]
-
-			if fieldName == "subject" {
-				curSubj = v
-			} else if fieldName != "sessionIndex" && fieldName != "rep" {
-				fieldNameSegs := strings.Split(fieldName, ".")
-
-				if fieldNameSegs[0] == "H" {
-					fieldNameSegs[0] = "D"
-				}
-
-				for segIdx, segVal := range fieldNameSegs {
-					replace, ok := convRuneMap[segVal]
-
-					if !ok {
-						continue
-					}
+	dataset, err := datasets.ReadCMU(f)
 
-					fieldNameSegs[segIdx] = string(replace)
-				}
-
-				if len(fieldNameSegs) > 3 {
-					fieldNameSegs = []string{fieldNameSegs[0], fieldNameSegs[2], fieldNameSegs[3]}
-				}
-
-				finalFieldName := strings.Join(fieldNameSegs, ".")
-
-				secondsValue, err := strconv.ParseFloat(v, 64)
-
-				if err != nil {
-					panic(err)
-				}
-
-				if err = s.AddPropertyByName(finalFieldName, secondsValue*1000); err != nil {
-					panic(err)
-				}
-			}
-		}
-
-		if len(curSubj) < 3 {
-			fmt.Println("Discard invalid subj")
-			continue
-		}
-
-		sameSubjectIdx := -1
-
-		for idx, s := range subjects {
-			if s.name == curSubj {
-				sameSubjectIdx = idx
-			}
-		}
+	if err != nil {
+		panic(err)
+	}
 
-		if sameSubjectIdx == -1 {
-			subjects = append(subjects, &subject{
-				name:       curSubj,
-				sessions:   []*keyize.Dynamics{s},
-				avgSession: nil,
-			})
-		} else {
-			subjects[sameSubjectIdx].sessions = append(subjects[sameSubjectIdx].sessions, s)
-		}
+	for _, name := range dataset.Subjects() {
+		subjects = append(subjects, &subject{
+			name:       name,
+			sessions:   dataset.SubjectDynamics(name),
+			avgSession: nil,
+		})
 	}
 
 	// Now summarize data for subject averages
@@ -183,7 +87,7 @@ func groupClosedSetIDStats(subjects []*subject) float64 {
 	for _, topSubj := range subjects {
 		wg.Add(1)
 
-		go func() {
+		go func(topSubj *subject) {
 			for _, cdyn := range topSubj.sessions {
 				var bestMatchSubj *subject
 				var bestMatchDist float64 = -2
@@ -205,7 +109,7 @@ func groupClosedSetIDStats(subjects []*subject) float64 {
 			}
 
 			wg.Done()
-		}()
+		}(topSubj)
 	}
 
 	wg.Wait()
@@ -0,0 +1,119 @@
+package keyize
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// KeyboardEvent is a DOM KeyboardEvent as logged by a browser.
+// See https://www.w3.org/TR/uievents/#events-keyboardevents
+type KeyboardEvent struct {
+	Key  string `json:"key"`
+	Code string `json:"code"`
+
+	// Type is the event type, such as "keydown" or "keyup"
+	Type string `json:"type"`
+
+	// TimeStamp is the DOMHighResTimeStamp of the event in (fractional) milliseconds
+	TimeStamp float64 `json:"timeStamp"`
+
+	Repeat bool `json:"repeat"`
+
+	// IsTrusted is false for events synthesized by scripts. It is nil if not logged.
+	IsTrusted *bool `json:"isTrusted,omitempty"`
+}
+
+var keyboardEventTypeKindMap = map[string]RawEventKind{
+	"keydown": KeyDown,
+	"keyup":   KeyUp,
+}
+
+// keyboardEventSubject returns the subject rune for KeyboardEvent key value key.
+// Named key values, such as "Shift", are mapped to named keys, such as KeyShift.
+func keyboardEventSubject(key string) (rune, bool) {
+	if r, size := utf8.DecodeRuneInString(key); r != utf8.RuneError && size == len(key) {
+		return r, true
+	}
+
+	return KeyRune(key)
+}
+
+// KeyboardEventsRecording converts KeyboardEvents, in the order they were dispatched, to a Recording.
+//
+// keydown and keyup events become KeyDown and KeyUp events, and the physical key is preserved in RecordingEvent.Code.
+// Auto-repeat keydown events (with repeat set) become KeyDown events with Repeat set.
+// Other event types, untrusted events and events with a key value which is neither a single character nor the name
+// of a named key (eg. "Dead" or "Unidentified") are skipped.
+// The Recording has microsecond Resolution and At values are relative to the first event which is not skipped.
+func KeyboardEventsRecording(events []*KeyboardEvent) (*Recording, error) {
+	rec := &Recording{
+		Events:     []*RecordingEvent{},
+		Resolution: time.Microsecond,
+	}
+
+	var start float64
+	var lastAt int
+
+	for i, ke := range events {
+		kind, ok := keyboardEventTypeKindMap[ke.Type]
+
+		if !ok || (ke.IsTrusted != nil && !*ke.IsTrusted) {
+			continue
+		}
+
+		subject, ok := keyboardEventSubject(ke.Key)
+
+		if !ok {
+			continue
+		}
+
+		if math.IsNaN(ke.TimeStamp) || math.IsInf(ke.TimeStamp, 0) {
+			return nil, errors.New("invalid timeStamp for event " + strconv.Itoa(i))
+		}
+
+		if len(rec.Events) == 0 {
+			start = ke.TimeStamp
+		}
+
+		at := int(math.Round((ke.TimeStamp - start) * 1000))
+
+		if at < lastAt {
+			return nil, errors.New("invalid timeStamp for event " + strconv.Itoa(i) + " is less than previous")
+		}
+
+		lastAt = at
+
+		rec.Events = append(rec.Events, &RecordingEvent{
+			At:      at,
+			Kind:    kind,
+			Subject: subject,
+			Code:    ke.Code,
+			Repeat:  kind == KeyDown && ke.Repeat,
+		})
+	}
+
+	return rec, nil
+}
+
+// ImportKeyboardEvents imports a JSON array of logged DOM KeyboardEvents from r.
+// See KeyboardEventsRecording for how events are converted.
+func ImportKeyboardEvents(r io.Reader) (*Recording, error) {
+	var events []*KeyboardEvent
+
+	if err := json.NewDecoder(r).Decode(&events); err != nil {
+		return nil, err
+	}
+
+	for i, ke := range events {
+		if ke == nil {
+			return nil, errors.New("invalid null KeyboardEvent " + strconv.Itoa(i))
+		}
+	}
+
+	return KeyboardEventsRecording(events)
+}
@@ -0,0 +1,73 @@
+package keyize
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleKeyboardEvents = `[
+	{"key":"Shift","code":"ShiftLeft","type":"keydown","timeStamp":1000.5,"repeat":false,"isTrusted":true},
+	{"key":"H","code":"KeyH","type":"keydown","timeStamp":1100.25,"repeat":false,"isTrusted":true},
+	{"key":"H","code":"KeyH","type":"keypress","timeStamp":1100.3,"repeat":false,"isTrusted":true},
+	{"key":"h","code":"KeyH","type":"keyup","timeStamp":1180.75,"repeat":false,"isTrusted":true},
+	{"key":"1","code":"Numpad1","type":"keydown","timeStamp":1200,"repeat":false,"isTrusted":false},
+	{"key":"Enter","code":"Enter","type":"keydown","timeStamp":1300.125,"repeat":false}
+]`
+
+func TestImportKeyboardEvents(t *testing.T) {
+	rec, err := ImportKeyboardEvents(strings.NewReader(sampleKeyboardEvents))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Resolution != time.Microsecond {
+		t.Fatalf("unexpected resolution %v", rec.Resolution)
+	}
+
+	expected := []RecordingEvent{
+		{At: 0, Kind: KeyDown, Subject: KeyShift, Code: "ShiftLeft"},
+		{At: 99750, Kind: KeyDown, Subject: 'H', Code: "KeyH"},
+		{At: 180250, Kind: KeyUp, Subject: 'h', Code: "KeyH"},
+		{At: 299625, Kind: KeyDown, Subject: '\n', Code: "Enter"},
+	}
+
+	if len(rec.Events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(rec.Events))
+	}
+
+	for i, e := range rec.Events {
+		if *e != expected[i] {
+			t.Errorf("event %d: %+v != %+v", i, *e, expected[i])
+		}
+	}
+
+	// Timings must be converted to milliseconds when exported
+
+	v1, err := rec.ExportKeyizeV1WithExtensions()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v1 != "d[Shift]{ShiftLeft}0dH{KeyH}99uh{KeyH}180d\n{Enter}299" {
+		t.Fatalf("unexpected export %q", v1)
+	}
+
+	if _, err := ImportKeyboardEvents(strings.NewReader(`[{"key":"a","type":"keydown","timeStamp":5},{"key":"a","type":"keyup","timeStamp":4}]`)); err == nil {
+		t.Fatal("expected error for decreasing timeStamp")
+	}
+
+	// At values are relative to the first event which is not skipped
+
+	rec, err = ImportKeyboardEvents(strings.NewReader(`[{"key":"Dead","type":"keydown","timeStamp":900},{"key":"a","type":"keydown","timeStamp":1000},{"key":"a","type":"keyup","timeStamp":1080}]`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.Events) != 2 || rec.Events[0].At != 0 || rec.Events[1].At != 80000 {
+		t.Fatal("unexpected At values")
+	}
+}
@@ -0,0 +1,289 @@
+package keyize
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// KeyizeB is a compact binary encoding of a Recording.
+//
+// An encoded Recording begins with keyizeBMagic and a version byte, followed by the Resolution of the recording
+// in nanoseconds as a uvarint and the count of events as a uvarint.
+// Each event is then encoded as a uvarint holding the difference between its At and that of the previous event
+// shifted left by two, with the low bit holding its kind (0 for KeyDown, 1 for KeyUp) and the next bit set if the event
+// has attributes, followed by its subject encoded as UTF-8.
+//
+// Attributes of an event follow its subject, beginning with a uvarint of keyizeBAttr flags describing which are present.
+// A Code is encoded as a uvarint length followed by its bytes. The repeat flag marks an auto-repeat KeyDown and has no data.
+const keyizeBMagic = "KZB"
+
+// Flags describing the attributes of a KeyizeB event
+const (
+	keyizeBAttrCode = 1 << iota
+	keyizeBAttrRepeat
+)
+
+// maxInt is the largest value representable by int
+const maxInt = int(^uint(0) >> 1)
+
+// maxKeyizeBDelta is the largest difference between the At values of consecutive events which may be encoded,
+// as the difference is shifted left by two
+const maxKeyizeBDelta = ^uint64(0) >> 2
+
+// KeyizeBVersion is the version of KeyizeB written by MarshalKeyizeB.
+// The format, including each attribute, was settled before its first release, so version 1 is the only version.
+// A later change to the format will use a new version, leaving version 1 decodable.
+const KeyizeBVersion byte = 1
+
+// MarshalKeyizeB encodes Recording r using the KeyizeB binary format.
+//
+// Events must be in order of At, and At values must not be less than 0.
+func MarshalKeyizeB(r *Recording) ([]byte, error) {
+	// Most events require a byte for the timing and kind, and a byte for the subject
+
+	b := make([]byte, 0, len(keyizeBMagic)+1+binary.MaxVarintLen64+len(r.Events)*3)
+
+	b = append(b, keyizeBMagic...)
+	b = append(b, KeyizeBVersion)
+
+	var varintBuf [binary.MaxVarintLen64]byte
+	var runeBuf [utf8.UTFMax]byte
+
+	n := binary.PutUvarint(varintBuf[:], uint64(r.resolution()))
+	b = append(b, varintBuf[:n]...)
+
+	n = binary.PutUvarint(varintBuf[:], uint64(len(r.Events)))
+	b = append(b, varintBuf[:n]...)
+
+	lastAt := 0
+
+	for i, e := range r.Events {
+		if e.At < 0 {
+			return nil, errors.New("invalid at value " + strconv.Itoa(e.At) + " is less than 0")
+		}
+
+		if e.At < lastAt {
+			return nil, errors.New("invalid at value " + strconv.Itoa(e.At) + " is less than previous")
+		}
+
+		var kindBit uint64
+
+		switch e.Kind {
+		case KeyDown:
+			kindBit = 0
+		case KeyUp:
+			kindBit = 1
+		default:
+			return nil, errors.New("invalid event kind " + strconv.Itoa(int(e.Kind)) + " for event " + strconv.Itoa(i))
+		}
+
+		if uint64(e.At-lastAt) > maxKeyizeBDelta {
+			return nil, errors.New("invalid at value " + strconv.Itoa(e.At) + " is too far from previous")
+		}
+
+		if e.Subject == utf8.RuneError || !utf8.ValidRune(e.Subject) {
+			return nil, errors.New("invalid subject rune for event " + strconv.Itoa(i))
+		}
+
+		if e.Code != "" && !isCodeName(e.Code) {
+			return nil, errors.New("invalid code for event " + strconv.Itoa(i))
+		}
+
+		var attrs uint64
+		var attrsBit uint64
+
+		if e.Code != "" {
+			attrs |= keyizeBAttrCode
+		}
+
+		if e.Repeat {
+			attrs |= keyizeBAttrRepeat
+		}
+
+		if attrs != 0 {
+			attrsBit = 2
+		}
+
+		n = binary.PutUvarint(varintBuf[:], uint64(e.At-lastAt)<<2|attrsBit|kindBit)
+		b = append(b, varintBuf[:n]...)
+
+		n = utf8.EncodeRune(runeBuf[:], e.Subject)
+		b = append(b, runeBuf[:n]...)
+
+		if attrs != 0 {
+			n = binary.PutUvarint(varintBuf[:], attrs)
+			b = append(b, varintBuf[:n]...)
+		}
+
+		if attrs&keyizeBAttrCode != 0 {
+			n = binary.PutUvarint(varintBuf[:], uint64(len(e.Code)))
+			b = append(b, varintBuf[:n]...)
+			b = append(b, e.Code...)
+		}
+
+		lastAt = e.At
+	}
+
+	return b, nil
+}
+
+// UnmarshalKeyizeB decodes a Recording encoded using the KeyizeB binary format.
+func UnmarshalKeyizeB(data []byte) (*Recording, error) {
+	if len(data) < len(keyizeBMagic)+1 || string(data[:len(keyizeBMagic)]) != keyizeBMagic {
+		return nil, errors.New("data is not KeyizeB")
+	}
+
+	version := data[len(keyizeBMagic)]
+
+	if version != KeyizeBVersion {
+		return nil, errors.New("unsupported KeyizeB version " + strconv.Itoa(int(version)))
+	}
+
+	pos := len(keyizeBMagic) + 1
+
+	v, n := binary.Uvarint(data[pos:])
+
+	if n <= 0 || v == 0 || v > uint64(time.Hour) {
+		return nil, errors.New("invalid KeyizeB resolution")
+	}
+
+	resolution := time.Duration(v)
+	pos += n
+
+	count, n := binary.Uvarint(data[pos:])
+
+	if n <= 0 {
+		return nil, errors.New("invalid KeyizeB event count")
+	}
+
+	pos += n
+
+	// Each event occupies at least two bytes, so a larger count cannot be valid
+
+	if count > uint64(len(data)-pos)/2 {
+		return nil, errors.New("KeyizeB event count " + strconv.FormatUint(count, 10) + " exceeds data length")
+	}
+
+	rec := &Recording{
+		Events: make([]*RecordingEvent, 0, int(count)),
+	}
+
+	if resolution != time.Millisecond {
+		rec.Resolution = resolution
+	}
+
+	var at uint64
+
+	for i := 0; i < int(count); i++ {
+		v, n := binary.Uvarint(data[pos:])
+
+		if n <= 0 {
+			return nil, errors.New("invalid KeyizeB timing for event " + strconv.Itoa(i))
+		}
+
+		pos += n
+
+		hasAttrs := v&2 != 0
+		at += v >> 2
+
+		if at > uint64(maxInt) {
+			return nil, errors.New("KeyizeB at value overflows for event " + strconv.Itoa(i))
+		}
+
+		e := &RecordingEvent{
+			At:   int(at),
+			Kind: KeyDown,
+		}
+
+		if v&1 == 1 {
+			e.Kind = KeyUp
+		}
+
+		subject, size := utf8.DecodeRune(data[pos:])
+
+		if subject == utf8.RuneError {
+			return nil, errors.New("invalid KeyizeB subject for event " + strconv.Itoa(i))
+		}
+
+		e.Subject = subject
+		pos += size
+
+		if hasAttrs {
+			n, err := unmarshalKeyizeBAttrs(data[pos:], e)
+
+			if err != nil {
+				return nil, errors.New(err.Error() + " for event " + strconv.Itoa(i))
+			}
+
+			pos += n
+		}
+
+		rec.Events = append(rec.Events, e)
+	}
+
+	if pos != len(data) {
+		return nil, errors.New("unexpected data following KeyizeB events")
+	}
+
+	return rec, nil
+}
+
+// unmarshalKeyizeBAttrs decodes the attributes at the start of data to RecordingEvent e,
+// returning the count of bytes they occupied.
+func unmarshalKeyizeBAttrs(data []byte, e *RecordingEvent) (int, error) {
+	attrs, pos := binary.Uvarint(data)
+
+	if pos <= 0 {
+		return 0, errors.New("invalid KeyizeB attributes")
+	}
+
+	if attrs&^(keyizeBAttrCode|keyizeBAttrRepeat) != 0 {
+		return 0, errors.New("unsupported KeyizeB attributes " + strconv.FormatUint(attrs, 2))
+	}
+
+	if attrs&keyizeBAttrCode != 0 {
+		l, n := binary.Uvarint(data[pos:])
+
+		if n <= 0 || l > uint64(len(data)-pos-n) {
+			return 0, errors.New("invalid KeyizeB code")
+		}
+
+		pos += n
+		e.Code = string(data[pos : pos+int(l)])
+		pos += int(l)
+
+		if !isCodeName(e.Code) {
+			return 0, errors.New("invalid KeyizeB code")
+		}
+	}
+
+	e.Repeat = attrs&keyizeBAttrRepeat != 0
+
+	return pos, nil
+}
+
+// KeyizeV1ToKeyizeB converts a recording of the Keyize V1 format to the KeyizeB binary format.
+func KeyizeV1ToKeyizeB(d string) ([]byte, error) {
+	rec, err := ImportKeyizeV1(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return MarshalKeyizeB(rec)
+}
+
+// KeyizeBToKeyizeV1 converts a recording of the KeyizeB binary format to the Keyize V1 format, as done by
+// Recording.ExportKeyizeV1.
+func KeyizeBToKeyizeV1(data []byte) (string, error) {
+	rec, err := UnmarshalKeyizeB(data)
+
+	if err != nil {
+		return "", err
+	}
+
+	return rec.ExportKeyizeV1()
+}
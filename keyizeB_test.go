@@ -0,0 +1,130 @@
+package keyize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarshalKeyizeB(t *testing.T) {
+	data, err := KeyizeV1ToKeyizeB(sampleKeyizeV1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) >= len(sampleKeyizeV1) {
+		t.Errorf("KeyizeB encoding (%d bytes) is not smaller than KeyizeV1 (%d bytes)", len(data), len(sampleKeyizeV1))
+	}
+
+	v1, err := KeyizeBToKeyizeV1(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v1 != sampleKeyizeV1 {
+		t.Fatalf("round-trip produced %q", v1)
+	}
+
+	// Truncated and trailing data must be rejected
+
+	if _, err := UnmarshalKeyizeB(data[:len(data)-1]); err == nil {
+		t.Error("expected error for truncated data")
+	}
+
+	if _, err := UnmarshalKeyizeB(append(data, 0)); err == nil {
+		t.Error("expected error for trailing data")
+	}
+
+	if _, err := UnmarshalKeyizeB([]byte("KZB\x7f\x00")); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+
+	// Millisecond resolution, one KeyDown of 'a' at 82
+
+	rec, err := UnmarshalKeyizeB([]byte("KZB\x01\xc0\x84\x3d\x01\xc8\x02a"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Resolution != 0 || len(rec.Events) != 1 || rec.Events[0].At != 82 || rec.Events[0].Kind != KeyDown || rec.Events[0].Subject != 'a' {
+		t.Fatalf("unexpected recording %+v", rec.Events)
+	}
+
+	// Resolution must be preserved
+
+	rec.Resolution = time.Microsecond
+
+	data, err = MarshalKeyizeB(rec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec, err = UnmarshalKeyizeB(data); err != nil || rec.Resolution != time.Microsecond {
+		t.Fatal("resolution was not preserved", err)
+	}
+
+	// Auto-repeats must be preserved, including through KeyizeV1
+
+	data, err = KeyizeV1ToKeyizeB("da{KeyA}0ra{KeyA}500ra530ua{KeyA}600")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec, err = UnmarshalKeyizeB(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if v1, err = rec.ExportKeyizeV1WithExtensions(); err != nil || v1 != "da{KeyA}0ra{KeyA}500ra530ua{KeyA}600" {
+		t.Fatalf("repeat round-trip produced %q (%v)", v1, err)
+	}
+
+	// By default, the output is readable by the web-recorder
+
+	if v1, err = KeyizeBToKeyizeV1(data); err != nil || v1 != "da0da500da530ua600" {
+		t.Fatalf("conversion produced %q (%v)", v1, err)
+	}
+
+	// Differences which cannot be encoded and invalid codes must be rejected
+
+	if _, err := MarshalKeyizeB(&Recording{Events: []*RecordingEvent{{At: maxInt, Kind: KeyDown, Subject: 'a'}}}); err == nil && uint64(maxInt) > maxKeyizeBDelta {
+		t.Error("expected error for at value too far from previous")
+	}
+
+	if _, err := MarshalKeyizeB(&Recording{Events: []*RecordingEvent{{Kind: KeyDown, Subject: 'a', Code: "Key A"}}}); err == nil {
+		t.Error("expected error for invalid code")
+	}
+
+	// Corrupt the code of the last event to "}eyA"
+
+	data[len(data)-4] = '}'
+
+	if _, err := UnmarshalKeyizeB(data); err == nil {
+		t.Error("expected error for invalid code")
+	}
+}
+
+func BenchmarkUnmarshalKeyizeB(b *testing.B) {
+	data, err := KeyizeV1ToKeyizeB(sampleKeyizeV1)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		if _, err := UnmarshalKeyizeB(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkImportKeyizeV1(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ImportKeyizeV1(sampleKeyizeV1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package keyize
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var runeEventKindMap = map[rune]RawEventKind{
+	'u':                KeyUp,
+	'd':                KeyDown,
+	keyizeV1RepeatRune: KeyDown,
+}
+
+// keyizeV1RepeatRune is the kind rune of auto-repeat KeyDowns
+const keyizeV1RepeatRune = 'r'
+
+var eventKindRuneMap = map[RawEventKind]rune{
+	KeyUp:   'u',
+	KeyDown: 'd',
+}
+
+// KeyizeV1Encoder writes RecordingEvents to an io.Writer using the Keyize V1 format.
+type KeyizeV1Encoder struct {
+	w   io.Writer
+	buf []byte
+
+	// lastAt is the At value of the last encoded event, used to ensure output remains importable
+	lastAt int
+
+	// Extensions enables writing Codes and auto-repeats, which are not understood by the web-recorder
+	Extensions bool
+}
+
+// NewKeyizeV1Encoder returns a new KeyizeV1Encoder which writes to w.
+func NewKeyizeV1Encoder(w io.Writer) *KeyizeV1Encoder {
+	return &KeyizeV1Encoder{
+		w: w,
+	}
+}
+
+// Encode writes RecordingEvent e to the underlying writer.
+//
+// Events must be encoded in order of At. Subjects are written verbatim, as done by the web-recorder, except that
+// named keys which do not produce a character, such as KeyShift, are written using their name in brackets (eg. "d[Shift]120").
+// Newline subjects are written as a literal newline, as done by the web-recorder. ImportKeyizeV1 also accepts "[Enter]".
+//
+// If enc.Extensions is set, Codes are written in braces following the subject (eg. "da{KeyA}120"), and auto-repeat
+// KeyDowns are written with the kind rune 'r' (eg. "ra240"). These are extensions to the format produced by the
+// web-recorder, so by default Codes are omitted and auto-repeats are written as ordinary KeyDowns.
+// A Code must be ASCII alphanumeric.
+func (enc *KeyizeV1Encoder) Encode(e *RecordingEvent) error {
+	kindRune, ok := eventKindRuneMap[e.Kind]
+
+	if !ok {
+		return errors.New("invalid event kind " + strconv.Itoa(int(e.Kind)))
+	}
+
+	if enc.Extensions && e.Kind == KeyDown && e.Repeat {
+		kindRune = keyizeV1RepeatRune
+	}
+
+	if e.Subject == utf8.RuneError || !utf8.ValidRune(e.Subject) {
+		return errors.New("invalid subject rune")
+	}
+
+	if e.Code != "" && !isCodeName(e.Code) {
+		return errors.New("invalid code '" + e.Code + "'")
+	}
+
+	if e.At < 0 {
+		return errors.New("invalid at value " + strconv.Itoa(e.At) + " is less than 0")
+	}
+
+	if e.At < enc.lastAt {
+		return errors.New("invalid at value " + strconv.Itoa(e.At) + " is less than previous")
+	}
+
+	enc.buf = append(enc.buf[:0], string(kindRune)...)
+
+	if name, ok := KeyName(e.Subject); ok && !IsCharacter(e.Subject) {
+		enc.buf = append(enc.buf, '[')
+		enc.buf = append(enc.buf, name...)
+		enc.buf = append(enc.buf, ']')
+	} else {
+		enc.buf = append(enc.buf, string(e.Subject)...)
+	}
+
+	if enc.Extensions && e.Code != "" {
+		enc.buf = append(enc.buf, '{')
+		enc.buf = append(enc.buf, e.Code...)
+		enc.buf = append(enc.buf, '}')
+	}
+
+	enc.buf = strconv.AppendInt(enc.buf, int64(e.At), 10)
+
+	if _, err := enc.w.Write(enc.buf); err != nil {
+		return err
+	}
+
+	enc.lastAt = e.At
+
+	return nil
+}
+
+// ExportKeyizeV1 exports Recording r using the Keyize V1 format.
+// The result may be imported again using ImportKeyizeV1, and by the web-recorder, so Codes and Repeat are not preserved.
+//
+// Keyize V1 uses millisecond resolution, so At values of recordings with a finer Resolution are truncated to milliseconds.
+func (r *Recording) ExportKeyizeV1() (string, error) {
+	return r.exportKeyizeV1(false)
+}
+
+// ExportKeyizeV1WithExtensions is like ExportKeyizeV1, but preserves Codes and Repeat using the extensions described
+// by KeyizeV1Encoder.Encode.
+func (r *Recording) ExportKeyizeV1WithExtensions() (string, error) {
+	return r.exportKeyizeV1(true)
+}
+
+func (r *Recording) exportKeyizeV1(extensions bool) (string, error) {
+	b := &strings.Builder{}
+
+	enc := NewKeyizeV1Encoder(b)
+	enc.Extensions = extensions
+
+	res := r.resolution()
+
+	for _, e := range r.Events {
+		if res != time.Millisecond {
+			converted := *e
+			converted.At = int(time.Duration(e.At) * res / time.Millisecond)
+
+			e = &converted
+		}
+
+		if err := enc.Encode(e); err != nil {
+			return "", err
+		}
+	}
+
+	return b.String(), nil
+}
+
+// maxKeyizeV1HeadSize is the largest size of an event excluding its At digits, plus one byte for the first digit.
+const maxKeyizeV1HeadSize = 1 + 2 + maxCodeLength + 2 + maxCodeLength + 1
+
+// maxKeyizeV1AtDigits is the largest count of digits which may be needed to represent a valid At value.
+// Any further digits are consumed but not buffered, so that malformed input cannot grow memory use.
+const maxKeyizeV1AtDigits = 20
+
+// ParseMode determines how input which does not form a Keyize V1 event is handled.
+type ParseMode int
+
+const (
+	// Lenient skips any input which does not form an event. This is the historical behaviour of ImportKeyizeV1.
+	Lenient ParseMode = iota
+
+	// Strict rejects any input which does not form an event with a ParseError.
+	Strict
+)
+
+// ParseErrorReason describes why Keyize V1 input was rejected.
+type ParseErrorReason int
+
+const (
+	// UnparsedInput indicates input which does not form an event. It is only reported in Strict mode.
+	UnparsedInput ParseErrorReason = iota
+
+	// BadSubject indicates an invalid subject rune
+	BadSubject
+
+	// BadKind indicates an unknown event kind rune
+	BadKind
+
+	// BadAt indicates an At value which could not be parsed
+	BadAt
+
+	// NonMonotonicAt indicates an At value less than that of the previous event
+	NonMonotonicAt
+
+	// NegativeAt indicates an At value less than 0
+	NegativeAt
+)
+
+var parseErrorReasonNames = map[ParseErrorReason]string{
+	UnparsedInput:  "unparsed input",
+	BadSubject:     "invalid subject rune",
+	BadKind:        "invalid event kind",
+	BadAt:          "invalid at value",
+	NonMonotonicAt: "at value is less than previous",
+	NegativeAt:     "at value is less than 0",
+}
+
+func (r ParseErrorReason) String() string {
+	if name, ok := parseErrorReasonNames[r]; ok {
+		return name
+	}
+
+	return "unknown reason " + strconv.Itoa(int(r))
+}
+
+// ParseError is returned when Keyize V1 input cannot be parsed.
+type ParseError struct {
+	// Offset is the byte offset in the input of the start of the rejected event or input
+	Offset int64
+
+	// Index is the index the rejected event would have had in the Recording
+	Index int
+
+	Reason ParseErrorReason
+
+	// detail further describes the rejected input
+	detail string
+
+	// err is the underlying error, if any
+	err error
+}
+
+func (e *ParseError) Error() string {
+	msg := e.Reason.String()
+
+	if e.detail != "" {
+		msg += " " + e.detail
+	}
+
+	return msg + " at offset " + strconv.FormatInt(e.Offset, 10) + " (event " + strconv.Itoa(e.Index) + ")"
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+// KeyizeV1Decoder reads RecordingEvents from an io.Reader containing the Keyize V1 format.
+//
+// Events are decoded incrementally, so memory use does not grow with the size of the input.
+type KeyizeV1Decoder struct {
+	// Mode determines how input which does not form an event is handled. It defaults to Lenient.
+	Mode ParseMode
+
+	r      *bufio.Reader
+	digits []byte
+
+	// offset is the count of bytes consumed from r
+	offset int64
+
+	// index is the count of events decoded
+	index int
+
+	// lastAt is the At value of the last decoded event, used to validate that At values are monotonic
+	lastAt int64
+
+	// err is the first error encountered. Once set, it is returned by every call to Next.
+	err error
+}
+
+// NewKeyizeV1Decoder returns a new KeyizeV1Decoder which reads from r.
+func NewKeyizeV1Decoder(r io.Reader) *KeyizeV1Decoder {
+	return &KeyizeV1Decoder{
+		r:      bufio.NewReader(r),
+		lastAt: -100,
+	}
+}
+
+// Next decodes and returns the next RecordingEvent.
+//
+// It returns io.EOF once the input has been exhausted. Invalid input results in a *ParseError.
+func (dec *KeyizeV1Decoder) Next() (*RecordingEvent, error) {
+	if dec.err != nil {
+		return nil, dec.err
+	}
+
+	e, err := dec.next()
+
+	if err != nil {
+		dec.err = err
+
+		return nil, err
+	}
+
+	dec.index++
+
+	return e, nil
+}
+
+func (dec *KeyizeV1Decoder) discard(n int) {
+	discarded, _ := dec.r.Discard(n)
+
+	dec.offset += int64(discarded)
+}
+
+func (dec *KeyizeV1Decoder) parseError(offset int64, reason ParseErrorReason, detail string, err error) *ParseError {
+	return &ParseError{
+		Offset: offset,
+		Index:  dec.index,
+		Reason: reason,
+		detail: detail,
+		err:    err,
+	}
+}
+
+func (dec *KeyizeV1Decoder) next() (*RecordingEvent, error) {
+	// Seek to the next position at which an event begins
+
+	var kindRune rune
+	var subjectRune rune
+	var eventCode string
+	var start int64
+
+	for {
+		head, err := dec.r.Peek(maxKeyizeV1HeadSize)
+
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if len(head) == 0 {
+			return nil, io.EOF
+		}
+
+		subject, code, headSize := matchKeyizeV1Head(head)
+
+		if headSize == 0 {
+			if dec.Mode == Strict {
+				return nil, dec.parseError(dec.offset, UnparsedInput, "", nil)
+			}
+
+			if len(head) < 3 {
+				// Not enough input remains to form another event
+				return nil, io.EOF
+			}
+
+			dec.discard(1)
+
+			continue
+		}
+
+		kindRune = rune(head[0])
+		subjectRune = subject
+		eventCode = code
+		start = dec.offset
+
+		dec.discard(headSize)
+
+		break
+	}
+
+	// Read all digits of At
+
+	dec.digits = dec.digits[:0]
+
+	for {
+		b, err := dec.r.ReadByte()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if !isASCIIDigit(b) {
+			dec.r.UnreadByte()
+
+			break
+		}
+
+		dec.offset++
+
+		if len(dec.digits) <= maxKeyizeV1AtDigits {
+			dec.digits = append(dec.digits, b)
+		}
+	}
+
+	// Validate event
+
+	if subjectRune == utf8.RuneError {
+		return nil, dec.parseError(start, BadSubject, "", nil)
+	}
+
+	eventKind, ok := runeEventKindMap[kindRune]
+
+	if !ok {
+		return nil, dec.parseError(start, BadKind, string(kindRune), nil)
+	}
+
+	at, err := strconv.ParseInt(string(dec.digits), 10, 64)
+
+	if err != nil {
+		return nil, dec.parseError(start, BadAt, string(dec.digits), err)
+	}
+
+	if at < dec.lastAt {
+		return nil, dec.parseError(start, NonMonotonicAt, strconv.FormatInt(at, 10), nil)
+	}
+
+	if at < 0 {
+		return nil, dec.parseError(start, NegativeAt, strconv.FormatInt(at, 10), nil)
+	}
+
+	dec.lastAt = at
+
+	return &RecordingEvent{
+		Kind:    eventKind,
+		At:      int(at),
+		Subject: subjectRune,
+		Code:    eventCode,
+		Repeat:  kindRune == keyizeV1RepeatRune,
+	}, nil
+}
+
+// matchKeyizeV1Head matches the start of an event at the beginning of head: a kind rune, a subject rune or named key
+// in brackets and an optional code in braces, which must be followed by a digit. It returns the size of the match, or 0 if there is none.
+func matchKeyizeV1Head(head []byte) (subject rune, code string, size int) {
+	if len(head) < 3 || head[0] < 'a' || head[0] > 'z' {
+		return 0, "", 0
+	}
+
+	subject, subjectSize := utf8.DecodeRune(head[1:])
+
+	if subject == '[' {
+		// A named key. A literal '[' subject is instead followed by a digit or code.
+
+		if end := bytes.IndexByte(head[1:], ']'); end > 1 {
+			if r, ok := KeyRune(string(head[2 : 1+end])); ok {
+				subject = r
+				subjectSize = end + 1
+			}
+		}
+	}
+
+	size = 1 + subjectSize
+
+	if size < len(head) && head[size] == '{' {
+		if end := bytes.IndexByte(head[size:], '}'); end > 0 && isCodeName(string(head[size+1:size+end])) {
+			code = string(head[size+1 : size+end])
+			size += end + 1
+		}
+	}
+
+	if size >= len(head) || !isASCIIDigit(head[size]) {
+		return 0, "", 0
+	}
+
+	return subject, code, size
+}
+
+func isASCIIDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// ImportKeyizeV1 imports a keystroke recording of the Keyize V1 format.
+// These recordings may be generated from users by a corresponding recording library.
+//
+// Input which does not form an event is skipped. Use ImportKeyizeV1WithMode with Strict to reject it instead.
+// To import large recordings without buffering them entirely, use KeyizeV1Decoder.
+func ImportKeyizeV1(d string) (*Recording, error) {
+	return ImportKeyizeV1WithMode(d, Lenient)
+}
+
+// ImportKeyizeV1WithMode imports a keystroke recording of the Keyize V1 format using ParseMode mode.
+//
+// Invalid input results in a *ParseError.
+func ImportKeyizeV1WithMode(d string, mode ParseMode) (*Recording, error) {
+	rec := &Recording{
+		Events: []*RecordingEvent{},
+	}
+
+	dec := NewKeyizeV1Decoder(strings.NewReader(d))
+	dec.Mode = mode
+
+	for {
+		e, err := dec.Next()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		rec.Events = append(rec.Events, e)
+	}
+
+	return rec, nil
+}
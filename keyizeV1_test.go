@@ -0,0 +1,170 @@
+package keyize
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const sampleKeyizeV1 = "dH357uH582de1110ue1389dl2345ul2853dl3604ul4299do4458uo4729d 5583u 5815dw5942uw6196do6770uo7254dr7507ur7907dl8904ul9411dd10384ud10637"
+
+func TestRecording_ExportKeyizeV1(t *testing.T) {
+	rec, err := ImportKeyizeV1(sampleKeyizeV1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := rec.ExportKeyizeV1()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exported != sampleKeyizeV1 {
+		t.Fatalf("export does not match original: %q", exported)
+	}
+
+	// Newline subjects are written literally, as done by the web-recorder, and must survive a round-trip
+
+	rec = &Recording{
+		Events: []*RecordingEvent{
+			{At: 0, Kind: KeyDown, Subject: '\n'},
+			{At: 12, Kind: KeyUp, Subject: '\n'},
+			{At: 20, Kind: KeyDown, Subject: '7'},
+		},
+	}
+
+	exported, err = rec.ExportKeyizeV1()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exported != "d\n0u\n12d720" {
+		t.Fatalf("unexpected export %q", exported)
+	}
+
+	imported, err := ImportKeyizeV1(exported)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(imported.Events) != 3 || imported.Events[1].Subject != '\n' || imported.Events[2].Subject != '7' || imported.Events[2].At != 20 {
+		t.Fatal("round-trip produced incorrect events")
+	}
+
+	// Newlines written by name are also imported
+
+	if imported, err = ImportKeyizeV1("d[Enter]0u[Enter]12d720"); err != nil || len(imported.Events) != 3 || imported.Events[0].Subject != '\n' {
+		t.Fatal("named newline subjects were not imported", err)
+	}
+
+	// Out of order events cannot be exported
+
+	rec.Events[2].At = 5
+
+	if _, err := rec.ExportKeyizeV1(); err == nil {
+		t.Fatal("expected error exporting out of order events")
+	}
+}
+
+func TestKeyizeV1Decoder_Next(t *testing.T) {
+	// Read one byte at a time to ensure events spanning reads are decoded
+
+	dec := NewKeyizeV1Decoder(iotest.OneByteReader(strings.NewReader("xx" + sampleKeyizeV1 + "\n")))
+
+	var events []*RecordingEvent
+
+	for {
+		e, err := dec.Next()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		events = append(events, e)
+	}
+
+	rec, err := ImportKeyizeV1(sampleKeyizeV1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != len(rec.Events) {
+		t.Fatalf("decoded %d events, expected %d", len(events), len(rec.Events))
+	}
+
+	for i, e := range events {
+		if *e != *rec.Events[i] {
+			t.Fatalf("event %d differs: %+v != %+v", i, e, rec.Events[i])
+		}
+	}
+
+	// Errors are sticky
+
+	dec = NewKeyizeV1Decoder(strings.NewReader("da10da5da20"))
+
+	if _, err := dec.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dec.Next(); err == nil {
+		t.Fatal("expected error for non-monotonic at")
+	}
+
+	if _, err := dec.Next(); err == nil || err == io.EOF {
+		t.Fatal("expected error to persist")
+	}
+}
+
+func TestImportKeyizeV1WithMode(t *testing.T) {
+	// Lenient mode skips unparsed input
+
+	rec, err := ImportKeyizeV1WithMode("da10??ua20", Lenient)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.Events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(rec.Events))
+	}
+
+	if _, err := ImportKeyizeV1WithMode(sampleKeyizeV1, Strict); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		input  string
+		offset int64
+		index  int
+		reason ParseErrorReason
+	}{
+		{"da10??ua20", 4, 1, UnparsedInput},
+		{"da10ua20d", 8, 2, UnparsedInput},
+		{"da10xa20", 4, 1, BadKind},
+		{"da10ua5", 4, 1, NonMonotonicAt},
+		{"da99999999999999999999999", 0, 0, BadAt},
+	}
+
+	for _, c := range cases {
+		_, err := ImportKeyizeV1WithMode(c.input, Strict)
+
+		var parseErr *ParseError
+
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%q: expected ParseError, got %v", c.input, err)
+		}
+
+		if parseErr.Offset != c.offset || parseErr.Index != c.index || parseErr.Reason != c.reason {
+			t.Errorf("%q: unexpected error %v", c.input, parseErr)
+		}
+	}
+}
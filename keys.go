@@ -0,0 +1,179 @@
+package keyize
+
+import (
+	"unicode/utf8"
+)
+
+// Named keys which produce a control character. These runes have always been used as subjects for these keys.
+const (
+	KeyBackspace rune = '\b'
+	KeyTab       rune = '\t'
+	KeyEnter     rune = '\n'
+	KeyEscape    rune = '\x1B'
+	KeyDelete    rune = '\x7F'
+)
+
+// Named keys which do not produce a character, such as modifiers, navigation and function keys.
+//
+// They are represented by runes in the Private Use Area so they may be used as RecordingEvent subjects and
+// DynamicsProperty keys, and are written using their name in brackets (eg. "[Shift]") in KeyizeV1 and property names.
+// Their values may only be appended to.
+const (
+	KeyShift rune = 0xE000 + iota
+	KeyControl
+	KeyAlt
+	KeyMeta
+	KeyAltGraph
+	KeyCapsLock
+	KeyFn
+	KeyNumLock
+	KeyScrollLock
+	KeyArrowDown
+	KeyArrowLeft
+	KeyArrowRight
+	KeyArrowUp
+	KeyEnd
+	KeyHome
+	KeyPageDown
+	KeyPageUp
+	KeyInsert
+	KeyContextMenu
+	KeyPrintScreen
+	KeyPause
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+)
+
+// keyNames holds the names of named keys. Names are W3C UI Events key values, as used by KeyboardEvent.key.
+// See https://www.w3.org/TR/uievents-key/
+var keyNames = map[rune]string{
+	KeyBackspace:   "Backspace",
+	KeyTab:         "Tab",
+	KeyEnter:       "Enter",
+	KeyEscape:      "Escape",
+	KeyDelete:      "Delete",
+	KeyShift:       "Shift",
+	KeyControl:     "Control",
+	KeyAlt:         "Alt",
+	KeyMeta:        "Meta",
+	KeyAltGraph:    "AltGraph",
+	KeyCapsLock:    "CapsLock",
+	KeyFn:          "Fn",
+	KeyNumLock:     "NumLock",
+	KeyScrollLock:  "ScrollLock",
+	KeyArrowDown:   "ArrowDown",
+	KeyArrowLeft:   "ArrowLeft",
+	KeyArrowRight:  "ArrowRight",
+	KeyArrowUp:     "ArrowUp",
+	KeyEnd:         "End",
+	KeyHome:        "Home",
+	KeyPageDown:    "PageDown",
+	KeyPageUp:      "PageUp",
+	KeyInsert:      "Insert",
+	KeyContextMenu: "ContextMenu",
+	KeyPrintScreen: "PrintScreen",
+	KeyPause:       "Pause",
+	KeyF1:          "F1",
+	KeyF2:          "F2",
+	KeyF3:          "F3",
+	KeyF4:          "F4",
+	KeyF5:          "F5",
+	KeyF6:          "F6",
+	KeyF7:          "F7",
+	KeyF8:          "F8",
+	KeyF9:          "F9",
+	KeyF10:         "F10",
+	KeyF11:         "F11",
+	KeyF12:         "F12",
+}
+
+var keyNameRuneMap = map[string]rune{}
+
+func init() {
+	for r, name := range keyNames {
+		keyNameRuneMap[name] = r
+	}
+}
+
+// KeyName returns the name of named key r (eg. "Shift" for KeyShift), if r is a named key.
+func KeyName(r rune) (string, bool) {
+	name, ok := keyNames[r]
+
+	return name, ok
+}
+
+// KeyRune returns the rune of the key named name (eg. KeyShift for "Shift"), if name is the name of a named key.
+func KeyRune(name string) (rune, bool) {
+	r, ok := keyNameRuneMap[name]
+
+	return r, ok
+}
+
+// IsCharacter reports whether key rune r produces a character, rather than being a named key
+// (such as KeyShift) or a physical key.
+func IsCharacter(r rune) bool {
+	if _, ok := PhysicalKeyCode(r); ok {
+		return false
+	}
+
+	return r < KeyShift || r > KeyF12
+}
+
+// keyName returns the name of key rune r as used in property names and KeyizeV1.
+func keyName(r rune) string {
+	if code, ok := PhysicalKeyCode(r); ok {
+		return "{" + code + "}"
+	}
+
+	if !IsCharacter(r) {
+		return "[" + keyNames[r] + "]"
+	}
+
+	return string(r)
+}
+
+// parseKeyName returns the key rune named by n, which may be a single rune,
+// a named key in brackets (eg. "[Shift]") or a physical key in braces (eg. "{ShiftLeft}").
+func parseKeyName(n string) (rune, bool) {
+	if len(n) > 2 && n[0] == '{' && n[len(n)-1] == '}' {
+		return PhysicalKeyRune(n[1 : len(n)-1])
+	}
+
+	if len(n) > 2 && n[0] == '[' && n[len(n)-1] == ']' {
+		return KeyRune(n[1 : len(n)-1])
+	}
+
+	r, size := utf8.DecodeRuneInString(n)
+
+	return r, size == len(n) && size > 0
+}
+
+// isCodeName reports whether s is usable as a code value or key name: non-empty, at most maxCodeLength bytes and ASCII alphanumeric.
+func isCodeName(s string) bool {
+	if len(s) == 0 || len(s) > maxCodeLength {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// maxCodeLength is the maximum length of a code value or key name in bytes
+const maxCodeLength = 32
@@ -0,0 +1,78 @@
+package keyize
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNamedKeys(t *testing.T) {
+	rec := &Recording{
+		Events: []*RecordingEvent{
+			{At: 0, Kind: KeyDown, Subject: KeyShift, Code: "ShiftLeft"},
+			{At: 90, Kind: KeyDown, Subject: 'H'},
+			{At: 150, Kind: KeyUp, Subject: KeyShift, Code: "ShiftLeft"},
+			{At: 170, Kind: KeyUp, Subject: 'H'},
+			{At: 300, Kind: KeyDown, Subject: '['},
+			{At: 380, Kind: KeyUp, Subject: '['},
+			{At: 400, Kind: KeyDown, Subject: KeyArrowLeft},
+		},
+	}
+
+	v1, err := rec.ExportKeyizeV1WithExtensions()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v1 != "d[Shift]{ShiftLeft}0dH90u[Shift]{ShiftLeft}150uH170d[300u[380d[ArrowLeft]400" {
+		t.Fatalf("unexpected export %q", v1)
+	}
+
+	imported, err := ImportKeyizeV1WithMode(v1, Strict)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, e := range imported.Events {
+		if *e != *rec.Events[i] {
+			t.Fatalf("event %d differs: %+v != %+v", i, e, rec.Events[i])
+		}
+	}
+
+	if text := rec.Text(); text != "H[" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	// Modifier timing becomes a real property
+
+	props := rec.Dynamics().Properties()
+
+	if props["D.[Shift]"] == nil || props["D.[Shift]"].Value != 150 || props["DD.[Shift].H"] == nil {
+		t.Fatal("missing Shift properties")
+	}
+
+	p, err := ParseDynamicsPropertyName("UD.[Shift].[ArrowLeft]")
+
+	if err != nil || p.KeyA != KeyShift || p.KeyB != KeyArrowLeft {
+		t.Fatal("failed to parse named keys", err)
+	}
+
+	// Named keys must be readable in JSON
+
+	data, err := json.Marshal(rec.Events[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"at":0,"kind":"KeyDown","subject":"[Shift]","code":"ShiftLeft"}` {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+
+	var e RecordingEvent
+
+	if err := json.Unmarshal(data, &e); err != nil || e != *rec.Events[0] {
+		t.Fatal("failed to decode named key", err)
+	}
+}
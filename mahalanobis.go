@@ -0,0 +1,297 @@
+package keyize
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// minMahalanobisStdDev is the least standard deviation, in milliseconds, of a property used by MahalanobisMatcher,
+// so properties which did not vary during enrollment do not produce infinite distances.
+const minMahalanobisStdDev = 1
+
+// MahalanobisMatcher finds the Mahalanobis distance of Dynamics from a set of enrollment Dynamics, which accounts for
+// correlation between properties, such as the DownDown and UpDown timings of a digraph.
+//
+// The covariance of the properties is estimated using Ledoit-Wolf shrinkage of their correlation matrix towards the
+// identity, so the estimate is well-conditioned even with fewer enrollment Dynamics than properties.
+type MahalanobisMatcher struct {
+	// names holds the names of the properties shared by every enrollment Dynamics, in order
+	names []string
+
+	// mean and stdDev hold the mean and standard deviation of each property
+	mean   []float64
+	stdDev []float64
+
+	// corr is the shrunk correlation matrix, row-major
+	corr []float64
+
+	// chol is the lower triangular Cholesky factor of corr, row-major
+	chol []float64
+
+	shrinkage float64
+}
+
+// NewMahalanobisMatcher returns a new MahalanobisMatcher trained from enrollment, over the properties shared by every
+// Dynamics in enrollment.
+//
+// At least two Dynamics are required, and they must share at least one property.
+func NewMahalanobisMatcher(enrollment []*Dynamics) (*MahalanobisMatcher, error) {
+	if len(enrollment) < 2 {
+		return nil, errors.New("at least 2 enrollment Dynamics are required")
+	}
+
+	m := &MahalanobisMatcher{}
+
+	for name := range enrollment[0].properties {
+		shared := true
+
+		for _, d := range enrollment[1:] {
+			if _, ok := d.properties[name]; !ok {
+				shared = false
+
+				break
+			}
+		}
+
+		if shared {
+			m.names = append(m.names, name)
+		}
+	}
+
+	if len(m.names) == 0 {
+		return nil, errors.New("enrollment Dynamics share no properties")
+	}
+
+	sort.Strings(m.names)
+
+	n := len(enrollment)
+	p := len(m.names)
+
+	// Standardize the enrollment properties
+
+	m.mean = make([]float64, p)
+	m.stdDev = make([]float64, p)
+
+	z := make([][]float64, n)
+
+	for k := range z {
+		z[k] = make([]float64, p)
+	}
+
+	for j, name := range m.names {
+		for _, d := range enrollment {
+			m.mean[j] += d.properties[name].Value
+		}
+
+		m.mean[j] /= float64(n)
+
+		for _, d := range enrollment {
+			diff := d.properties[name].Value - m.mean[j]
+			m.stdDev[j] += diff * diff
+		}
+
+		m.stdDev[j] = math.Max(math.Sqrt(m.stdDev[j]/float64(n)), minMahalanobisStdDev)
+
+		for k, d := range enrollment {
+			z[k][j] = (d.properties[name].Value - m.mean[j]) / m.stdDev[j]
+		}
+	}
+
+	// Sample correlation matrix S
+
+	s := make([]float64, p*p)
+
+	for _, zk := range z {
+		for i := 0; i < p; i++ {
+			for j := 0; j < p; j++ {
+				s[i*p+j] += zk[i] * zk[j] / float64(n)
+			}
+		}
+	}
+
+	// Ledoit-Wolf shrinkage towards mu*I, where mu is the mean variance.
+	// d2 is the squared distance of S from the target, and b2 the estimated error of S, bounded by d2.
+
+	mu := 0.0
+
+	for i := 0; i < p; i++ {
+		mu += s[i*p+i]
+	}
+
+	mu /= float64(p)
+
+	d2 := 0.0
+
+	for i := 0; i < p; i++ {
+		for j := 0; j < p; j++ {
+			diff := s[i*p+j]
+
+			if i == j {
+				diff -= mu
+			}
+
+			d2 += diff * diff
+		}
+	}
+
+	b2 := 0.0
+
+	for _, zk := range z {
+		for i := 0; i < p; i++ {
+			for j := 0; j < p; j++ {
+				diff := zk[i]*zk[j] - s[i*p+j]
+				b2 += diff * diff
+			}
+		}
+	}
+
+	b2 = math.Min(b2/float64(n*n), d2)
+
+	m.shrinkage = 1.0
+
+	if d2 > 0 {
+		m.shrinkage = b2 / d2
+	}
+
+	// With no more enrollment Dynamics than properties, S is singular, so the shrinkage is bounded below
+
+	if n <= p {
+		m.shrinkage = math.Max(m.shrinkage, float64(p-n+1)/float64(p))
+	}
+
+	// If no property varied during enrollment, S is zero, so the identity is used instead
+
+	target := mu
+
+	if target == 0 {
+		target = 1
+	}
+
+	var ok bool
+
+	if m.chol, ok = m.shrink(s, p, target); !ok {
+		// S may still be singular, such as when the enrollment properties are collinear, so shrink fully
+
+		m.shrinkage = 1
+
+		if m.chol, ok = m.shrink(s, p, target); !ok {
+			return nil, errors.New("covariance of enrollment Dynamics is not positive definite")
+		}
+	}
+
+	return m, nil
+}
+
+// shrink sets the correlation matrix of MahalanobisMatcher m by shrinking p by p sample correlation matrix s towards
+// target times the identity, returning its Cholesky factor, or false if it is not positive definite.
+func (m *MahalanobisMatcher) shrink(s []float64, p int, target float64) ([]float64, bool) {
+	m.corr = make([]float64, p*p)
+
+	for i := 0; i < p; i++ {
+		for j := 0; j < p; j++ {
+			m.corr[i*p+j] = (1 - m.shrinkage) * s[i*p+j]
+		}
+
+		m.corr[i*p+i] += m.shrinkage * target
+	}
+
+	return cholesky(m.corr, p)
+}
+
+// Properties returns the names of the properties used by MahalanobisMatcher m, in order.
+func (m *MahalanobisMatcher) Properties() []string {
+	return append([]string(nil), m.names...)
+}
+
+// Shrinkage returns the intensity (0 to 1) of the shrinkage applied to the correlation matrix of MahalanobisMatcher m.
+func (m *MahalanobisMatcher) Shrinkage() float64 {
+	return m.shrinkage
+}
+
+// Dist returns the Mahalanobis distance of Dynamics a from the enrollment Dynamics of MahalanobisMatcher m.
+//
+// If a lacks some properties of m, the distance is found over the properties it has.
+// An error is returned if it has none of them.
+func (m *MahalanobisMatcher) Dist(a *Dynamics) (float64, error) {
+	p := len(m.names)
+
+	var indices []int
+	var z []float64
+
+	for j, name := range m.names {
+		if prop, ok := a.properties[name]; ok {
+			indices = append(indices, j)
+			z = append(z, (prop.Value-m.mean[j])/m.stdDev[j])
+		}
+	}
+
+	if len(indices) == 0 {
+		return 0, errors.New("Dynamics shares none of the " + strconv.Itoa(p) + " matcher properties")
+	}
+
+	chol := m.chol
+
+	if len(indices) < p {
+		// The marginal distribution of the shared properties uses the corresponding submatrix
+
+		q := len(indices)
+		sub := make([]float64, q*q)
+
+		for i, ii := range indices {
+			for j, jj := range indices {
+				sub[i*q+j] = m.corr[ii*p+jj]
+			}
+		}
+
+		// A principal submatrix of a positive definite matrix is positive definite
+		chol, _ = cholesky(sub, q)
+	}
+
+	// Solve L y = z, so that the squared distance z' corr^-1 z is y'y
+
+	q := len(z)
+	dist := 0.0
+
+	for i := 0; i < q; i++ {
+		y := z[i]
+
+		for j := 0; j < i; j++ {
+			y -= chol[i*q+j] * z[j]
+		}
+
+		z[i] = y / chol[i*q+i]
+		dist += z[i] * z[i]
+	}
+
+	return math.Sqrt(dist), nil
+}
+
+// cholesky returns the lower triangular Cholesky factor of symmetric n by n row-major matrix a,
+// or false if a is not positive definite.
+func cholesky(a []float64, n int) ([]float64, bool) {
+	l := make([]float64, n*n)
+
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := a[i*n+j]
+
+			for k := 0; k < j; k++ {
+				sum -= l[i*n+k] * l[j*n+k]
+			}
+
+			if i == j {
+				if sum <= 0 {
+					return nil, false
+				}
+
+				l[i*n+i] = math.Sqrt(sum)
+			} else {
+				l[i*n+j] = sum / l[j*n+j]
+			}
+		}
+	}
+
+	return l, true
+}
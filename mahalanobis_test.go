@@ -0,0 +1,180 @@
+package keyize
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMahalanobisMatcher_Dist(t *testing.T) {
+	// DD.t.h and UD.t.h are strongly correlated, D.t is independent of both
+
+	var enrollment []*Dynamics
+
+	for i, v := range []float64{180, 200, 220, 190, 210, 200} {
+		d := NewDynamics()
+
+		d.AddPropertyByName("DD.t.h", v)
+		d.AddPropertyByName("UD.t.h", v-90+float64(i%2))
+		d.AddPropertyByName("D.t", 90+float64(i%3)*5)
+
+		enrollment = append(enrollment, d)
+	}
+
+	// Properties not shared by every enrollment Dynamics are excluded
+
+	enrollment[0].AddPropertyByName("D.h", 100)
+
+	m, err := NewMahalanobisMatcher(enrollment)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := m.Properties(); len(names) != 3 || names[0] != "D.t" || names[1] != "DD.t.h" || names[2] != "UD.t.h" {
+		t.Fatalf("unexpected properties %v", names)
+	}
+
+	if s := m.Shrinkage(); s <= 0 || s > 1 {
+		t.Fatalf("unexpected shrinkage %f", s)
+	}
+
+	sample := func(dd, ud float64) *Dynamics {
+		d := NewDynamics()
+
+		d.AddPropertyByName("DD.t.h", dd)
+		d.AddPropertyByName("UD.t.h", ud)
+		d.AddPropertyByName("D.t", 95)
+
+		return d
+	}
+
+	consistent, err := m.Dist(sample(230, 140.5))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inconsistent, err := m.Dist(sample(230, 80.5))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if consistent >= inconsistent {
+		t.Fatalf("correlated deviation %f not closer than uncorrelated deviation %f", consistent, inconsistent)
+	}
+
+	// The mean itself is at distance 0
+
+	if dist, err := m.Dist(sample(200, 110.5)); err != nil || math.Abs(dist) > 1e-9 {
+		t.Fatalf("unexpected distance of mean %f %v", dist, err)
+	}
+
+	// Missing properties use the marginal distribution of those shared
+
+	partial := NewDynamics()
+	partial.AddPropertyByName("DD.t.h", 230)
+
+	if dist, err := m.Dist(partial); err != nil || math.IsNaN(dist) || dist <= 0 {
+		t.Fatalf("unexpected partial distance %f %v", dist, err)
+	}
+
+	unrelated := NewDynamics()
+	unrelated.AddPropertyByName("D.x", 100)
+
+	if _, err := m.Dist(unrelated); err == nil {
+		t.Fatal("expected error for Dynamics sharing no properties")
+	}
+
+	// Invalid enrollments
+
+	if _, err := NewMahalanobisMatcher(enrollment[:1]); err == nil {
+		t.Fatal("expected error for single enrollment Dynamics")
+	}
+
+	if _, err := NewMahalanobisMatcher([]*Dynamics{enrollment[0], unrelated}); err == nil {
+		t.Fatal("expected error for enrollment sharing no properties")
+	}
+}
+
+func TestNewMahalanobisMatcher(t *testing.T) {
+	// No property varies, so deviations are measured in units of minMahalanobisStdDev
+
+	var enrollment []*Dynamics
+
+	for i := 0; i < 3; i++ {
+		d := NewDynamics()
+
+		d.AddPropertyByName("D.a", 100)
+		d.AddPropertyByName("DD.a.b", 200)
+
+		enrollment = append(enrollment, d)
+	}
+
+	m, err := NewMahalanobisMatcher(enrollment)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sample := NewDynamics()
+
+	sample.AddPropertyByName("D.a", 103)
+	sample.AddPropertyByName("DD.a.b", 196)
+
+	if dist, err := m.Dist(sample); err != nil || math.Abs(dist-5) > 1e-9 {
+		t.Fatalf("unexpected distance %f %v", dist, err)
+	}
+
+	// Two enrollment Dynamics with several properties have a singular sample correlation matrix
+
+	enrollment = nil
+
+	for _, v := range [][3]float64{{100, 50, 90}, {120, 40, 80}} {
+		d := NewDynamics()
+
+		d.AddPropertyByName("DD.a.b", v[0])
+		d.AddPropertyByName("UD.a.b", v[1])
+		d.AddPropertyByName("D.a", v[2])
+
+		enrollment = append(enrollment, d)
+	}
+
+	m, err = NewMahalanobisMatcher(enrollment)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := m.Shrinkage(); s < 2.0/3 || s > 1 {
+		t.Fatalf("unexpected shrinkage %f", s)
+	}
+
+	sample = NewDynamics()
+
+	sample.AddPropertyByName("DD.a.b", 110)
+	sample.AddPropertyByName("UD.a.b", 45)
+	sample.AddPropertyByName("D.a", 85)
+
+	if dist, err := m.Dist(sample); err != nil || math.Abs(dist) > 1e-9 {
+		t.Fatalf("unexpected distance of mean %f %v", dist, err)
+	}
+
+	sample.AddPropertyByName("DD.a.b", 130)
+
+	if dist, err := m.Dist(sample); err != nil || math.IsNaN(dist) || dist <= 0 {
+		t.Fatalf("unexpected distance %f %v", dist, err)
+	}
+}
+
+func TestCholesky(t *testing.T) {
+	l, ok := cholesky([]float64{4, 2, 2, 3}, 2)
+
+	if !ok || l[0] != 2 || l[1] != 0 || l[2] != 1 || math.Abs(l[3]-math.Sqrt(2)) > 1e-12 {
+		t.Fatalf("unexpected factor %v", l)
+	}
+
+	if _, ok := cholesky([]float64{1, 2, 2, 1}, 2); ok {
+		t.Fatal("expected failure for indefinite matrix")
+	}
+}
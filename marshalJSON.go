@@ -0,0 +1,345 @@
+package keyize
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// MarshalText encodes RawEventKind k as its name.
+func (k RawEventKind) MarshalText() ([]byte, error) {
+	name, ok := rawEventKindNames[k]
+
+	if !ok {
+		return nil, errors.New("cannot marshal unknown " + k.String())
+	}
+
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a RawEventKind from its name.
+func (k *RawEventKind) UnmarshalText(text []byte) error {
+	for kind, name := range rawEventKindNames {
+		if name == string(text) {
+			*k = kind
+
+			return nil
+		}
+	}
+
+	return errors.New("invalid event kind '" + string(text) + "'")
+}
+
+// MarshalText encodes DynamicsPropertyKind k as its name.
+func (k DynamicsPropertyKind) MarshalText() ([]byte, error) {
+	name, ok := dynamicsPropertyKindNames[k]
+
+	if !ok {
+		return nil, errors.New("cannot marshal unknown " + k.String())
+	}
+
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a DynamicsPropertyKind from its name.
+func (k *DynamicsPropertyKind) UnmarshalText(text []byte) error {
+	for kind, name := range dynamicsPropertyKindNames {
+		if name == string(text) {
+			*k = kind
+
+			return nil
+		}
+	}
+
+	return errors.New("invalid property kind '" + string(text) + "'")
+}
+
+type recordingEventJSON struct {
+	At      int          `json:"at"`
+	Kind    RawEventKind `json:"kind"`
+	Subject string       `json:"subject"`
+	Code    string       `json:"code,omitempty"`
+	Repeat  bool         `json:"repeat,omitempty"`
+}
+
+// MarshalJSON encodes RecordingEvent e as a JSON object, such as {"at":357,"kind":"KeyDown","subject":"H"}.
+// Named keys such as KeyShift are encoded using their name in brackets (eg. "[Shift]").
+func (e *RecordingEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&recordingEventJSON{
+		At:      e.At,
+		Kind:    e.Kind,
+		Subject: keyName(e.Subject),
+		Code:    e.Code,
+		Repeat:  e.Repeat,
+	})
+}
+
+// UnmarshalJSON decodes RecordingEvent e from a JSON object produced by MarshalJSON.
+func (e *RecordingEvent) UnmarshalJSON(data []byte) error {
+	var v recordingEventJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	subject, ok := parseKeyName(v.Subject)
+
+	if !ok || subject == utf8.RuneError {
+		return errors.New("invalid subject '" + v.Subject + "'")
+	}
+
+	e.At = v.At
+	e.Kind = v.Kind
+	e.Subject = subject
+	e.Code = v.Code
+	e.Repeat = v.Repeat
+
+	return nil
+}
+
+type recordingJSON struct {
+	Events []*RecordingEvent `json:"events"`
+
+	// Resolution is a duration string (eg. "1µs"), omitted for millisecond resolution
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// MarshalJSON encodes Recording r as a JSON object holding its events and, if not milliseconds, its resolution.
+func (r *Recording) MarshalJSON() ([]byte, error) {
+	events := r.Events
+
+	if events == nil {
+		events = []*RecordingEvent{}
+	}
+
+	v := &recordingJSON{
+		Events: events,
+	}
+
+	if res := r.resolution(); res != time.Millisecond {
+		v.Resolution = res.String()
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes Recording r from a JSON object produced by MarshalJSON.
+func (r *Recording) UnmarshalJSON(data []byte) error {
+	var v recordingJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	for _, e := range v.Events {
+		if e == nil {
+			return errors.New("invalid null event")
+		}
+	}
+
+	var resolution time.Duration
+
+	if v.Resolution != "" {
+		var err error
+
+		resolution, err = time.ParseDuration(v.Resolution)
+
+		if err != nil || resolution <= 0 {
+			return errors.New("invalid resolution '" + v.Resolution + "'")
+		}
+	}
+
+	r.Events = v.Events
+	r.Resolution = resolution
+
+	return nil
+}
+
+type dynamicsPropertyJSON struct {
+	Name  string                `json:"name"`
+	Kind  *DynamicsPropertyKind `json:"kind,omitempty"`
+	Value float64               `json:"value"`
+	Stats *PropertyStats        `json:"stats,omitempty"`
+}
+
+// MarshalJSON encodes DynamicsProperty p as a JSON object, such as {"name":"DD.a.b","kind":"DownDown","value":112.5}.
+// Stats, if present, are encoded as "stats".
+func (p *DynamicsProperty) MarshalJSON() ([]byte, error) {
+	kind := p.Kind
+
+	return json.Marshal(&dynamicsPropertyJSON{
+		Name:  p.Name(),
+		Kind:  &kind,
+		Value: p.Value,
+		Stats: p.Stats,
+	})
+}
+
+// UnmarshalJSON decodes DynamicsProperty p from a JSON object produced by MarshalJSON.
+//
+// The name is validated using ParseDynamicsPropertyName. The kind may be omitted, but if present must match the name.
+func (p *DynamicsProperty) UnmarshalJSON(data []byte) error {
+	var v dynamicsPropertyJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	parsed, err := ParseDynamicsPropertyName(v.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if v.Kind != nil && *v.Kind != parsed.Kind {
+		return errors.New("kind " + v.Kind.String() + " does not match name '" + v.Name + "'")
+	}
+
+	parsed.Value = v.Value
+	parsed.Stats = v.Stats
+
+	*p = *parsed
+
+	return nil
+}
+
+type dynamicsJSON struct {
+	Properties []*DynamicsProperty `json:"properties"`
+}
+
+// MarshalJSON encodes Dynamics d as a JSON object holding its properties in order of name.
+func (d *Dynamics) MarshalJSON() ([]byte, error) {
+	props := make([]*DynamicsProperty, 0, len(d.properties))
+
+	for _, p := range d.properties {
+		props = append(props, p)
+	}
+
+	sort.Slice(props, func(i, j int) bool {
+		return props[i].Name() < props[j].Name()
+	})
+
+	return json.Marshal(&dynamicsJSON{
+		Properties: props,
+	})
+}
+
+// UnmarshalJSON replaces the properties of Dynamics d with those decoded from a JSON object produced by MarshalJSON.
+func (d *Dynamics) UnmarshalJSON(data []byte) error {
+	var v dynamicsJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	properties := map[string]*DynamicsProperty{}
+
+	for _, p := range v.Properties {
+		if p == nil {
+			return errors.New("invalid null property")
+		}
+
+		properties[p.Name()] = p
+	}
+
+	d.properties = properties
+
+	return nil
+}
+
+type templatePropertyJSON struct {
+	Name       string  `json:"name"`
+	Count      int     `json:"count"`
+	Mean       float64 `json:"mean"`
+	Variance   float64 `json:"variance"`
+	MeanAbsDev float64 `json:"meanAbsDev"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+
+	SumSqWeights float64 `json:"sumSqWeights"`
+}
+
+type templateJSON struct {
+	LearningRate float64                 `json:"learningRate,omitempty"`
+	Window       int                     `json:"window,omitempty"`
+	Properties   []*templatePropertyJSON `json:"properties"`
+}
+
+// MarshalJSON encodes Template t as a JSON object holding its configuration and the state of its properties in order of name,
+// such as {"learningRate":0.1,"properties":[{"name":"D.a","count":12,"mean":95.5,"variance":64,"meanAbsDev":6.5,"min":80,"max":120,"sumSqWeights":0.11}]}.
+func (t *Template) MarshalJSON() ([]byte, error) {
+	v := &templateJSON{
+		LearningRate: t.LearningRate,
+		Window:       t.Window,
+		Properties:   make([]*templatePropertyJSON, 0, len(t.properties)),
+	}
+
+	for _, name := range t.sortedNames() {
+		tp := t.properties[name]
+
+		v.Properties = append(v.Properties, &templatePropertyJSON{
+			Name:       name,
+			Count:      tp.count,
+			Mean:       tp.mean,
+			Variance:   tp.variance,
+			MeanAbsDev: tp.meanAbsDev,
+			Min:        tp.min,
+			Max:        tp.max,
+
+			SumSqWeights: tp.sumSqWeights,
+		})
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON replaces Template t with one decoded from a JSON object produced by MarshalJSON.
+func (t *Template) UnmarshalJSON(data []byte) error {
+	var v templateJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if v.LearningRate < 0 || v.LearningRate > 1 || v.Window < 0 {
+		return errors.New("invalid template learning rate or window")
+	}
+
+	properties := map[string]*templateProperty{}
+
+	for _, p := range v.Properties {
+		if p == nil {
+			return errors.New("invalid null property")
+		}
+
+		prop, err := ParseDynamicsPropertyName(p.Name)
+
+		if err != nil {
+			return err
+		}
+
+		if p.Count < 1 || p.Variance < 0 || p.MeanAbsDev < 0 || p.SumSqWeights <= 0 || p.SumSqWeights > 1 {
+			return errors.New("invalid count, deviation or weights for property '" + p.Name + "'")
+		}
+
+		properties[prop.Name()] = &templateProperty{
+			prop:       prop,
+			count:      p.Count,
+			mean:       p.Mean,
+			variance:   p.Variance,
+			meanAbsDev: p.MeanAbsDev,
+			min:        p.Min,
+			max:        p.Max,
+
+			sumSqWeights: p.SumSqWeights,
+		}
+	}
+
+	t.LearningRate = v.LearningRate
+	t.Window = v.Window
+	t.properties = properties
+
+	return nil
+}
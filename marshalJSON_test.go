@@ -0,0 +1,82 @@
+package keyize
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRecording_MarshalJSON(t *testing.T) {
+	rec := &Recording{
+		Events: []*RecordingEvent{
+			{At: 357, Kind: KeyDown, Subject: 'H'},
+			{At: 582, Kind: KeyUp, Subject: '\n'},
+		},
+	}
+
+	data, err := json.Marshal(rec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"events":[{"at":357,"kind":"KeyDown","subject":"H"},{"at":582,"kind":"KeyUp","subject":"\n"}]}` {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+
+	decoded := &Recording{}
+
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded.Events) != 2 || *decoded.Events[0] != *rec.Events[0] || *decoded.Events[1] != *rec.Events[1] {
+		t.Fatal("decoded Recording has incorrect events")
+	}
+
+	if err := json.Unmarshal([]byte(`{"events":[{"at":1,"kind":"KeyDown","subject":"ab"}]}`), decoded); err == nil {
+		t.Fatal("expected error for multi-rune subject")
+	}
+}
+
+func TestDynamics_MarshalJSON(t *testing.T) {
+	d := NewDynamics()
+
+	d.AddPropertyByName("UD.o.E", 3)
+	d.AddPropertyByName("D.H", 5.5)
+
+	d.Properties()["D.H"].Stats = &PropertyStats{Count: 2, StdDev: 0.5, Median: 5.5, Min: 5, Max: 6}
+
+	data, err := json.Marshal(d)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"properties":[{"name":"D.H","kind":"Dwell","value":5.5,"stats":{"count":2,"stdDev":0.5,"median":5.5,"min":5,"max":6}},{"name":"UD.o.E","kind":"UpDown","value":3}]}` {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+
+	decoded := NewDynamics()
+
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	props := decoded.Properties()
+
+	if len(props) != 2 || props["D.H"].Value != 5.5 || props["D.H"].Stats.Max != 6 || props["UD.o.E"].KeyB != 'E' || props["UD.o.E"].Stats != nil {
+		t.Fatal("decoded Dynamics has incorrect properties")
+	}
+
+	invalid := []string{
+		`{"properties":[{"name":"X.H","value":1}]}`,
+		`{"properties":[{"name":"D.H","kind":"DownDown","value":1}]}`,
+		`{"properties":[{"name":"D.H","kind":"Sideways","value":1}]}`,
+	}
+
+	for _, s := range invalid {
+		if err := json.Unmarshal([]byte(s), decoded); err == nil {
+			t.Errorf("expected error decoding %s", s)
+		}
+	}
+}
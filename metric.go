@@ -0,0 +1,285 @@
+package keyize
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// PropertyPair is a property shared between two Dynamics being compared.
+type PropertyPair struct {
+	Name string
+
+	// A is the property of the Dynamics whose Distance method is called, such as a template, and B that of the other
+	A *DynamicsProperty
+	B *DynamicsProperty
+}
+
+// Metric is a distance metric used by Dynamics.Distance to compare two Dynamics.
+//
+// Distance is passed the properties shared between the Dynamics, of which there is at least one, in order of name,
+// and the DynamicsPropertyKindScaleMap used to scale their values. It holds the optimized defaults overridden by
+// DistanceOptions.Scale, so it includes a scale for every DynamicsPropertyKind, and may be modified.
+type Metric interface {
+	Distance(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64
+}
+
+// MetricFunc is a function which may be used as a Metric.
+type MetricFunc func(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64
+
+// Distance calls f.
+func (f MetricFunc) Distance(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	return f(pairs, scale)
+}
+
+var (
+	// Manhattan is the sum of the absolute differences of the scaled values
+	Manhattan Metric = MetricFunc(manhattanDist)
+
+	// Euclidean is the square root of the sum of the squared differences of the scaled values
+	Euclidean Metric = MetricFunc(euclideanDist)
+
+	// AvgDiff is the mean absolute difference of the scaled values
+	AvgDiff Metric = MetricFunc(avgDiffDist)
+
+	// Chebyshev is the greatest absolute difference of the scaled values
+	Chebyshev Metric = MetricFunc(chebyshevDist)
+
+	// Cosine is 1 minus the cosine similarity of the scaled values, so it compares the rhythm of typing
+	// regardless of its overall speed. It is 1 if exactly one of the Dynamics has only zero values.
+	Cosine Metric = MetricFunc(cosineDist)
+
+	// Canberra is the sum of the absolute differences of the values, each divided by the sum of their magnitudes.
+	// It is unaffected by scaling.
+	Canberra Metric = MetricFunc(canberraDist)
+
+	// ScaledManhattan is the sum of the absolute differences of the values, each divided by the mean absolute deviation
+	// of the property of A, as done by Dynamics.ScaledManhattanDist. Properties whose mean absolute deviation is unknown
+	// are scaled by kind instead.
+	ScaledManhattan Metric = MetricFunc(scaledManhattanDist)
+)
+
+// Minkowski is the Minkowski distance of order P of the scaled values. P should be at least 1, and Distance panics
+// if it is not greater than 0.
+//
+// A P of 1 is the same as Manhattan, and 2 the same as Euclidean.
+type Minkowski struct {
+	P float64
+}
+
+// Distance returns the Minkowski distance of order m.P between pairs.
+func (m Minkowski) Distance(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	if !(m.P > 0) {
+		panic("Minkowski order must be greater than 0")
+	}
+
+	sum := 0.0
+
+	for _, p := range pairs {
+		sum += math.Pow(scaledDiff(p, scale), m.P)
+	}
+
+	return math.Pow(sum, 1/m.P)
+}
+
+// DistanceOptions configures Dynamics.Distance and Dynamics.Compare.
+//
+// The overlap requirements guard against matching Dynamics which share few properties, such as those of different
+// typed text, whose distance is otherwise small simply because little is compared.
+type DistanceOptions struct {
+	// Scale is used for scaling. If nil, the optimized defaults are used, as they are for kinds it does not include.
+	Scale DynamicsPropertyKindScaleMap
+
+	// MinShared is the least number of properties which must be shared. At least one is always required.
+	MinShared int
+
+	// MinSharedProportion is the least proportion (0 to 1) of the properties considered which must be shared
+	MinSharedProportion float64
+
+	// SharedMethod selects the properties considered by MinSharedProportion and MissingPenalty
+	SharedMethod SharedPropertiesMethod
+
+	// MissingPenalty is added to the distance for each property considered which is not shared
+	MissingPenalty float64
+}
+
+// Comparison is the result of comparing two Dynamics.
+type Comparison struct {
+	Distance float64
+
+	// Shared is the number of properties shared, and Total the number of properties considered
+	// with respect to DistanceOptions.SharedMethod
+	Shared int
+	Total  int
+
+	// Proportion is the proportion of the properties considered which are shared, or 0 if none are considered
+	Proportion float64
+}
+
+// Compare uses Metric metric to find the distance between Dynamics d and a over the properties they share,
+// adding DistanceOptions.MissingPenalty for each property considered which is not shared.
+//
+// nil may be passed for opts to use the defaults. If the overlap requirements of opts are not met, such as when
+// d and a share no properties, an error is returned along with a Comparison whose Distance is +Inf.
+func (d *Dynamics) Compare(a *Dynamics, metric Metric, opts *DistanceOptions) (*Comparison, error) {
+	if opts == nil {
+		opts = &DistanceOptions{}
+	}
+
+	c := &Comparison{
+		Distance: math.Inf(1),
+	}
+
+	c.Shared, c.Total = d.SharedProperties(a, opts.SharedMethod)
+
+	if c.Total > 0 {
+		c.Proportion = float64(c.Shared) / float64(c.Total)
+	}
+
+	if c.Shared == 0 {
+		return c, errors.New("no shared properties")
+	}
+
+	if c.Shared < opts.MinShared {
+		return c, errors.New("only " + strconv.Itoa(c.Shared) + " shared properties, " + strconv.Itoa(opts.MinShared) + " required")
+	}
+
+	if c.Proportion < opts.MinSharedProportion {
+		return c, errors.New("proportion of shared properties " + strconv.FormatFloat(c.Proportion, 'f', -1, 64) + " is less than " + strconv.FormatFloat(opts.MinSharedProportion, 'f', -1, 64))
+	}
+
+	c.Distance = metric.Distance(d.sharedPairs(a), opts.Scale.withDefaults()) + float64(c.Total-c.Shared)*opts.MissingPenalty
+
+	return c, nil
+}
+
+// Distance uses Metric metric to find the distance between Dynamics d and a, as done by Compare.
+//
+// nil may be passed for opts to use the defaults. If the overlap requirements of opts are not met, such as when
+// d and a share no properties, +Inf is returned.
+func (d *Dynamics) Distance(a *Dynamics, metric Metric, opts *DistanceOptions) float64 {
+	c, _ := d.Compare(a, metric, opts)
+
+	return c.Distance
+}
+
+// sharedPairs returns the properties shared between Dynamics d and a, in order of name.
+func (d *Dynamics) sharedPairs(a *Dynamics) []PropertyPair {
+	var pairs []PropertyPair
+
+	for name, p := range d.properties {
+		if q, ok := a.properties[name]; ok {
+			pairs = append(pairs, PropertyPair{
+				Name: name,
+				A:    p,
+				B:    q,
+			})
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Name < pairs[j].Name
+	})
+
+	return pairs
+}
+
+// scaledDiff returns the absolute difference of the scaled values of PropertyPair p.
+func scaledDiff(p PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	s := scale.scale(p.A.Kind)
+
+	return math.Abs(p.A.Value*s - p.B.Value*s)
+}
+
+func manhattanDist(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	dist := 0.0
+
+	for _, p := range pairs {
+		dist += scaledDiff(p, scale)
+	}
+
+	return dist
+}
+
+func euclideanDist(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	dist := 0.0
+
+	for _, p := range pairs {
+		dist += math.Pow(scaledDiff(p, scale), 2)
+	}
+
+	return math.Sqrt(dist)
+}
+
+func avgDiffDist(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	return manhattanDist(pairs, scale) / float64(len(pairs))
+}
+
+func chebyshevDist(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	dist := 0.0
+
+	for _, p := range pairs {
+		dist = math.Max(dist, scaledDiff(p, scale))
+	}
+
+	return dist
+}
+
+func cosineDist(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	dot := 0.0
+	normA := 0.0
+	normB := 0.0
+
+	for _, p := range pairs {
+		s := scale.scale(p.A.Kind)
+
+		x := p.A.Value * s
+		y := p.B.Value * s
+
+		dot += x * y
+		normA += x * x
+		normB += y * y
+	}
+
+	if normA == 0 && normB == 0 {
+		return 0
+	}
+
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
+}
+
+func canberraDist(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	dist := 0.0
+
+	for _, p := range pairs {
+		denom := math.Abs(p.A.Value) + math.Abs(p.B.Value)
+
+		if denom == 0 {
+			// Both values are zero, so they do not differ
+			continue
+		}
+
+		dist += math.Abs(p.A.Value-p.B.Value) / denom
+	}
+
+	return dist
+}
+
+func scaledManhattanDist(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+	dist := 0.0
+
+	for _, p := range pairs {
+		if p.A.Stats != nil && p.A.Stats.MeanAbsDev > 0 {
+			dist += math.Abs(p.A.Value-p.B.Value) / p.A.Stats.MeanAbsDev
+		} else {
+			dist += scaledDiff(p, scale)
+		}
+	}
+
+	return dist
+}
@@ -0,0 +1,166 @@
+package keyize
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDynamics_Distance(t *testing.T) {
+	d := NewDynamics()
+
+	d.AddPropertyByName("D.a", 100)
+	d.AddPropertyByName("DD.a.b", 200)
+	d.AddPropertyByName("D.c", 50)
+
+	a := NewDynamics()
+
+	a.AddPropertyByName("D.a", 110)
+	a.AddPropertyByName("DD.a.b", 180)
+
+	// Spreads for ScaledManhattan
+
+	d.Properties()["D.a"].Stats = &PropertyStats{MeanAbsDev: 5}
+	d.Properties()["DD.a.b"].Stats = &PropertyStats{MeanAbsDev: 10}
+
+	opts := &DistanceOptions{
+		Scale: DynamicsPropertyKindScaleMap{Dwell: 1, DownDown: 1},
+	}
+
+	tests := []struct {
+		name     string
+		metric   Metric
+		expected float64
+	}{
+		{"Manhattan", Manhattan, 30},
+		{"Euclidean", Euclidean, math.Sqrt(500)},
+		{"AvgDiff", AvgDiff, 15},
+		{"Chebyshev", Chebyshev, 20},
+		{"Minkowski1", Minkowski{P: 1}, 30},
+		{"Minkowski3", Minkowski{P: 3}, math.Cbrt(9000)},
+		{"Cosine", Cosine, 1 - 47000/math.Sqrt(50000*44500)},
+		{"Canberra", Canberra, 10.0/210 + 20.0/380},
+		{"ScaledManhattan", ScaledManhattan, 10.0/5 + 20.0/10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if dist := d.Distance(a, tt.metric, opts); math.Abs(dist-tt.expected) > 1e-9 {
+				t.Fatalf("expected %f, got %f", tt.expected, dist)
+			}
+		})
+	}
+
+	// Custom metrics receive the shared properties in order of name, and the scale with defaults for omitted kinds
+
+	var names []string
+	var received DynamicsPropertyKindScaleMap
+
+	custom := MetricFunc(func(pairs []PropertyPair, scale DynamicsPropertyKindScaleMap) float64 {
+		names = nil
+
+		for _, p := range pairs {
+			names = append(names, p.Name)
+		}
+
+		received = scale
+
+		return 0
+	})
+
+	d.Distance(a, custom, nil)
+
+	if len(names) != 2 || names[0] != "D.a" || names[1] != "DD.a.b" {
+		t.Fatalf("unexpected pairs %v", names)
+	}
+
+	if received[Dwell] != 1.1 || received[DownDown] != 1/19.4 || received[NGraph] != 1/38.8 {
+		t.Fatalf("unexpected default scale %v", received)
+	}
+
+	d.Distance(a, custom, &DistanceOptions{Scale: DynamicsPropertyKindScaleMap{Dwell: 1}})
+
+	if received[Dwell] != 1 || received[DownDown] != 1/19.4 || len(received) != len(defaultDynamicsPropertyKindScaleMap) {
+		t.Fatalf("unexpected merged scale %v", received)
+	}
+
+	// Minkowski distance is only defined for a positive order
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic for Minkowski order 0")
+			}
+		}()
+
+		d.Distance(a, Minkowski{}, nil)
+	}()
+
+	// The existing distance methods use the default scale when nil is passed
+
+	if dist := d.ManhattanDist(a, nil); math.Abs(dist-(1.1*10+20/19.4)) > 1e-9 {
+		t.Fatalf("unexpected ManhattanDist %f", dist)
+	}
+}
+
+func TestDynamics_Compare(t *testing.T) {
+	d := NewDynamics()
+
+	d.AddPropertyByName("D.a", 100)
+	d.AddPropertyByName("DD.a.b", 200)
+	d.AddPropertyByName("D.c", 50)
+
+	a := NewDynamics()
+
+	a.AddPropertyByName("D.a", 110)
+	a.AddPropertyByName("UD.x.y", 30)
+
+	// Overlap is reported, and each property considered which is not shared is penalized
+
+	c, err := d.Compare(a, Manhattan, &DistanceOptions{
+		Scale:          DynamicsPropertyKindScaleMap{Dwell: 1},
+		MissingPenalty: 5,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Shared != 1 || c.Total != 4 || c.Proportion != 0.25 || c.Distance != 10+3*5 {
+		t.Fatalf("unexpected comparison %+v", c)
+	}
+
+	// Left considers only the properties of d
+
+	if c, err := d.Compare(a, Manhattan, &DistanceOptions{SharedMethod: Left, MinSharedProportion: 0.5}); err == nil || c.Total != 3 || !math.IsInf(c.Distance, 1) {
+		t.Fatalf("expected error for insufficient proportion %+v %v", c, err)
+	}
+
+	if _, err := d.Compare(a, Manhattan, &DistanceOptions{MinShared: 2}); err == nil {
+		t.Fatal("expected error for insufficient shared properties")
+	}
+
+	// Dynamics sharing no properties are not a perfect match
+
+	unrelated := NewDynamics()
+	unrelated.AddPropertyByName("D.z", 100)
+
+	if _, err := d.Compare(unrelated, Manhattan, nil); err == nil {
+		t.Fatal("expected error for no shared properties")
+	}
+
+	if dist := d.ManhattanDist(unrelated, nil); !math.IsInf(dist, 1) {
+		t.Fatalf("unexpected ManhattanDist %f", dist)
+	}
+
+	if diff := d.AvgScaledPropDiff(unrelated, nil); !math.IsInf(diff, 1) {
+		t.Fatalf("unexpected AvgScaledPropDiff %f", diff)
+	}
+
+	if match := d.ProportionMatch(unrelated); match != 0 {
+		t.Fatalf("unexpected ProportionMatch %f", match)
+	}
+
+	if p := NewDynamics().ProportionSharedProperties(NewDynamics(), Both); p != 0 {
+		t.Fatalf("unexpected proportion of empty Dynamics %f", p)
+	}
+}
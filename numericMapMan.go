@@ -1,11 +1,6 @@
 package keyize
 
-// Note for the future: once vanilla Go supports generics, floatSliceMapMan should be converted
-// to numericSliceMapMan. Then in recording.Dynamics(), ints should be used for most calculations until the final
-// creation of a Dynamics.
-//
-// This will allow the avoidance of unnecessary float math operations.
-
+// floatSliceMapMan collects the values of each property by name, as used by AggregateDynamics.
 type floatSliceMapMan map[string][]float64
 
 func newFloatSliceMapMan() floatSliceMapMan {
@@ -21,19 +16,3 @@ func (f floatSliceMapMan) Add(k string, v float64) {
 		f[k] = []float64{v}
 	}
 }
-
-func (f floatSliceMapMan) Reduce() map[string]float64 {
-	reduced := map[string]float64{}
-
-	for k, vs := range f {
-		t := 0.0
-
-		for _, v := range vs {
-			t += v
-		}
-
-		reduced[k] = t / float64(len(vs))
-	}
-
-	return reduced
-}
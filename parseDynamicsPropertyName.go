@@ -3,31 +3,52 @@ package keyize
 import (
 	"errors"
 	"regexp"
+	"strconv"
+	"strings"
 	"unicode/utf8"
 )
 
-var dynamicsPropertyNameRegex *regexp.Regexp = regexp.MustCompile(`^(DD|D|UD)\.(.|[^a])(?:\.(.|[^a]))?$`)
+// dynamicsPropertyNameRegex matches property names. Each key is either a single rune, a named key in brackets (eg. "[Shift]"),
+// or a physical key code in braces (eg. "{ShiftLeft}").
+var dynamicsPropertyNameRegex *regexp.Regexp = regexp.MustCompile(`^(DD|DU|D|UD|UU)\.(\{[A-Za-z0-9]+\}|\[[A-Za-z0-9]+\]|.|[^a])(?:\.(\{[A-Za-z0-9]+\}|\[[A-Za-z0-9]+\]|.|[^a]))?$`)
+
+// nGraphNameRegex matches NGraph property names, such as "DD3.t.h.e". The keys are parsed by parseKeySequence.
+var nGraphNameRegex *regexp.Regexp = regexp.MustCompile(`(?s)^DD([0-9]+)\.(.+)$`)
 
 var kindCodeKindMap = map[string]DynamicsPropertyKind{
 	"DD": DownDown,
 	"UD": UpDown,
+	"UU": UpUp,
+	"DU": DownUp,
 	"D":  Dwell,
 }
 
 func ParseDynamicsPropertyName(n string) (*DynamicsProperty, error) {
+	if components := nGraphNameRegex.FindStringSubmatch(n); components != nil {
+		return parseNGraphName(n, components)
+	}
+
 	components := dynamicsPropertyNameRegex.FindStringSubmatch(n)
 
 	if components == nil {
 		return nil, errors.New("failed to parse name '" + n + "'")
 	}
 
-	rune1, _ := utf8.DecodeRuneInString(components[2])
+	rune1, ok := parseKeyName(components[2])
+
+	if !ok {
+		return nil, errors.New("failed to parse name '" + n + "': unknown key " + components[2])
+	}
 
 	// In a properly formatted Dwell name, there may be no components[3]
 	var rune2 rune
 
-	if len(components) >= 4 {
-		rune2, _ = utf8.DecodeRuneInString(components[3])
+	if len(components) >= 4 && components[3] != "" {
+		rune2, ok = parseKeyName(components[3])
+
+		if !ok {
+			return nil, errors.New("failed to parse name '" + n + "': unknown key " + components[3])
+		}
 	}
 
 	return &DynamicsProperty{
@@ -37,3 +58,75 @@ func ParseDynamicsPropertyName(n string) (*DynamicsProperty, error) {
 		Value: 0,
 	}, nil
 }
+
+// parseNGraphName parses NGraph property name n, given its nGraphNameRegex submatches.
+func parseNGraphName(n string, components []string) (*DynamicsProperty, error) {
+	count, err := strconv.Atoi(components[1])
+
+	if err != nil || count < minNGraphLength {
+		return nil, errors.New("failed to parse name '" + n + "': invalid key count " + components[1])
+	}
+
+	keys, ok := parseKeySequence(components[2])
+
+	if !ok || len(keys) != count {
+		return nil, errors.New("failed to parse name '" + n + "': expected " + components[1] + " keys")
+	}
+
+	return &DynamicsProperty{
+		Kind:  NGraph,
+		KeyA:  keys[0],
+		KeyB:  keys[len(keys)-1],
+		Keys:  keys,
+		Value: 0,
+	}, nil
+}
+
+// parseKeySequence parses a sequence of key names separated by '.' (eg. "t.h.e" or "[Shift].{KeyA}.b").
+func parseKeySequence(s string) ([]rune, bool) {
+	var keys []rune
+
+	for {
+		// A key in brackets or braces, which must be followed by a separator, or else a single rune
+
+		var k rune
+		size := 0
+
+		if len(s) > 0 && (s[0] == '[' || s[0] == '{') {
+			closing := byte(']')
+
+			if s[0] == '{' {
+				closing = '}'
+			}
+
+			if end := strings.IndexByte(s, closing); end > 1 && (end+1 == len(s) || s[end+1] == '.') {
+				var ok bool
+
+				if k, ok = parseKeyName(s[:end+1]); ok {
+					size = end + 1
+				}
+			}
+		}
+
+		if size == 0 {
+			k, size = utf8.DecodeRuneInString(s)
+
+			if size == 0 || (k == utf8.RuneError && size == 1) {
+				return nil, false
+			}
+		}
+
+		keys = append(keys, k)
+		s = s[size:]
+
+		if s == "" {
+			return keys, true
+		}
+
+		if s[0] != '.' {
+			return nil, false
+		}
+
+		s = s[1:]
+	}
+}
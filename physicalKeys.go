@@ -0,0 +1,51 @@
+package keyize
+
+// physicalKeyBase is the first rune used to represent physical keys.
+// Physical keys are represented by runes in Supplementary Private Use Area-A so they may be used anywhere a key rune is,
+// such as DynamicsProperty KeyA and KeyB, without colliding with produced characters.
+const physicalKeyBase rune = 0xF0000
+
+// physicalKeyCodes holds the W3C UI Events code values of the physical keys which may be represented as runes.
+// See https://www.w3.org/TR/uievents-code/
+//
+// The rune of each code is physicalKeyBase plus its index, so codes may only be appended.
+var physicalKeyCodes = []string{
+	"Backquote", "Backslash", "BracketLeft", "BracketRight", "Comma", "Digit0", "Digit1", "Digit2", "Digit3", "Digit4",
+	"Digit5", "Digit6", "Digit7", "Digit8", "Digit9", "Equal", "IntlBackslash", "IntlRo", "IntlYen", "KeyA", "KeyB",
+	"KeyC", "KeyD", "KeyE", "KeyF", "KeyG", "KeyH", "KeyI", "KeyJ", "KeyK", "KeyL", "KeyM", "KeyN", "KeyO", "KeyP",
+	"KeyQ", "KeyR", "KeyS", "KeyT", "KeyU", "KeyV", "KeyW", "KeyX", "KeyY", "KeyZ", "Minus", "Period", "Quote",
+	"Semicolon", "Slash", "AltLeft", "AltRight", "Backspace", "CapsLock", "ContextMenu", "ControlLeft", "ControlRight",
+	"Enter", "MetaLeft", "MetaRight", "ShiftLeft", "ShiftRight", "Space", "Tab", "Delete", "End", "Help", "Home",
+	"Insert", "PageDown", "PageUp", "ArrowDown", "ArrowLeft", "ArrowRight", "ArrowUp", "NumLock", "Numpad0", "Numpad1",
+	"Numpad2", "Numpad3", "Numpad4", "Numpad5", "Numpad6", "Numpad7", "Numpad8", "Numpad9", "NumpadAdd", "NumpadComma",
+	"NumpadDecimal", "NumpadDivide", "NumpadEnter", "NumpadEqual", "NumpadMultiply", "NumpadSubtract", "Escape", "F1",
+	"F2", "F3", "F4", "F5", "F6", "F7", "F8", "F9", "F10", "F11", "F12", "Fn", "PrintScreen", "ScrollLock", "Pause",
+}
+
+var physicalKeyCodeRuneMap = map[string]rune{}
+
+func init() {
+	for i, code := range physicalKeyCodes {
+		physicalKeyCodeRuneMap[code] = physicalKeyBase + rune(i)
+	}
+}
+
+// PhysicalKeyRune returns the rune representing the physical key with W3C UI Events code value code (eg. "ShiftLeft").
+// Such runes name the physical key rather than a produced character, and are written as "{ShiftLeft}" in property names.
+// Unlike named keys such as KeyShift, they distinguish between keys with the same function (eg. "ShiftLeft" and "ShiftRight").
+func PhysicalKeyRune(code string) (rune, bool) {
+	r, ok := physicalKeyCodeRuneMap[code]
+
+	return r, ok
+}
+
+// PhysicalKeyCode returns the W3C UI Events code value of the physical key represented by rune r, if r represents one.
+func PhysicalKeyCode(r rune) (string, bool) {
+	idx := int(r - physicalKeyBase)
+
+	if idx < 0 || idx >= len(physicalKeyCodes) {
+		return "", false
+	}
+
+	return physicalKeyCodes[idx], true
+}
@@ -0,0 +1,77 @@
+package keyize
+
+import (
+	"testing"
+)
+
+func TestRecording_PhysicalDynamics(t *testing.T) {
+	// "1" typed on the main row then on the numpad, with codes carried through KeyizeV1
+
+	rec, err := ImportKeyizeV1WithMode("d1{Digit1}0u1{Digit1}80d1{Numpad1}200u1{Numpad1}290", Strict)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Events[2].Code != "Numpad1" {
+		t.Fatalf("code was not imported: %+v", rec.Events[2])
+	}
+
+	// By subject, both presses share properties
+
+	if props := rec.Dynamics().Properties(); props["D.1"].Value != 85 || props["DD.1.1"].Value != 200 {
+		t.Fatal("incorrect subject properties")
+	}
+
+	props := rec.PhysicalDynamics().Properties()
+
+	if props["D.{Digit1}"].Value != 80 || props["D.{Numpad1}"].Value != 90 || props["DD.{Digit1}.{Numpad1}"].Value != 200 || props["UD.{Digit1}.{Numpad1}"].Value != 120 {
+		for _, p := range props {
+			t.Log(p.Name(), p.Value)
+		}
+
+		t.Fatal("incorrect physical properties")
+	}
+
+	// Physical key names must parse back to the same property
+
+	p, err := ParseDynamicsPropertyName("DD.{Digit1}.{Numpad1}")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := PhysicalKeyCode(p.KeyB); code != "Numpad1" || p.Kind != DownDown {
+		t.Fatalf("incorrect parsed property %+v", p)
+	}
+
+	if _, err := ParseDynamicsPropertyName("D.{NotAKey}"); err == nil {
+		t.Fatal("expected error for unknown physical key")
+	}
+
+	// Single rune braces remain usable as keys
+
+	if p, err := ParseDynamicsPropertyName("DD.{.}"); err != nil || p.KeyA != '{' || p.KeyB != '}' {
+		t.Fatal("failed to parse brace keys", err)
+	}
+
+	// Codes must survive KeyizeB
+
+	data, err := MarshalKeyizeB(rec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := UnmarshalKeyizeB(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, e := range decoded.Events {
+		if *e != *rec.Events[i] {
+			t.Fatalf("event %d differs: %+v != %+v", i, e, rec.Events[i])
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package keyize
+
+import (
+	"math"
+	"sort"
+)
+
+// PropertyStats describes the distribution of the timings from which the Value of a DynamicsProperty was computed.
+type PropertyStats struct {
+	// Count is the number of timings
+	Count int `json:"count"`
+
+	// StdDev is the sample standard deviation of the timings. It is 0 if Count is less than 2.
+	// For Template, it is exponentially weighted and corrected for bias using the effective sample size.
+	StdDev float64 `json:"stdDev"`
+
+	// Median is the median of the timings. It is exact for up to 5 timings, and otherwise estimated using the P² algorithm.
+	Median float64 `json:"median"`
+
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+
+	// MeanAbsDev is the mean absolute deviation of the timings from their mean, or 0 if unknown.
+	// It is set by AvgDynamics, AggregateDynamics and Template, but not by extraction, as it cannot be computed online.
+	MeanAbsDev float64 `json:"meanAbsDev,omitempty"`
+}
+
+// statsAccumulator computes the mean and PropertyStats of a stream of values in constant space.
+//
+// The mean and variance are computed using Welford's algorithm, and the median is estimated using the P² algorithm
+// of Jain and Chlamtac, both of which are numerically stable.
+type statsAccumulator struct {
+	count int
+
+	mean float64
+	m2   float64
+
+	min float64
+	max float64
+
+	// q holds the heights of the P² markers, which are the first values until there are 5.
+	// n holds the positions of the markers, and np their desired positions.
+	q  [5]float64
+	n  [5]float64
+	np [5]float64
+}
+
+// p2Increments are the increments of the desired P² marker positions for the median
+var p2Increments = [5]float64{0, 0.25, 0.5, 0.75, 1}
+
+func (a *statsAccumulator) add(v float64) {
+	a.count++
+
+	// Welford
+
+	delta := v - a.mean
+	a.mean += delta / float64(a.count)
+	a.m2 += delta * (v - a.mean)
+
+	if a.count == 1 || v < a.min {
+		a.min = v
+	}
+
+	if a.count == 1 || v > a.max {
+		a.max = v
+	}
+
+	// P²
+
+	if a.count <= 5 {
+		a.q[a.count-1] = v
+
+		if a.count == 5 {
+			sort.Float64s(a.q[:])
+
+			a.n = [5]float64{1, 2, 3, 4, 5}
+			a.np = [5]float64{1, 2, 3, 4, 5}
+		}
+
+		return
+	}
+
+	// Find the cell k containing v, adjusting the extreme markers
+
+	var k int
+
+	switch {
+	case v < a.q[0]:
+		a.q[0] = v
+		k = 0
+	case v >= a.q[4]:
+		a.q[4] = v
+		k = 3
+	default:
+		for v >= a.q[k+1] {
+			k++
+		}
+	}
+
+	for i := k + 1; i < 5; i++ {
+		a.n[i]++
+	}
+
+	for i := range a.np {
+		a.np[i] += p2Increments[i]
+	}
+
+	// Adjust the heights of the middle markers if they are off their desired positions
+
+	for i := 1; i <= 3; i++ {
+		d := a.np[i] - a.n[i]
+
+		if (d >= 1 && a.n[i+1]-a.n[i] > 1) || (d <= -1 && a.n[i-1]-a.n[i] < -1) {
+			d = math.Copysign(1, d)
+
+			q := a.parabolic(i, d)
+
+			if a.q[i-1] < q && q < a.q[i+1] {
+				a.q[i] = q
+			} else {
+				j := i + int(d)
+
+				a.q[i] += d * (a.q[j] - a.q[i]) / (a.n[j] - a.n[i])
+			}
+
+			a.n[i] += d
+		}
+	}
+}
+
+// parabolic returns the P² piecewise-parabolic prediction of the height of marker i moved by d.
+func (a *statsAccumulator) parabolic(i int, d float64) float64 {
+	return a.q[i] + d/(a.n[i+1]-a.n[i-1])*
+		((a.n[i]-a.n[i-1]+d)*(a.q[i+1]-a.q[i])/(a.n[i+1]-a.n[i])+
+			(a.n[i+1]-a.n[i]-d)*(a.q[i]-a.q[i-1])/(a.n[i]-a.n[i-1]))
+}
+
+func (a *statsAccumulator) median() float64 {
+	if a.count > 5 {
+		return a.q[2]
+	}
+
+	return median(a.q[:a.count])
+}
+
+func (a *statsAccumulator) stats() *PropertyStats {
+	s := &PropertyStats{
+		Count:  a.count,
+		Median: a.median(),
+		Min:    a.min,
+		Max:    a.max,
+	}
+
+	if a.count > 1 {
+		s.StdDev = math.Sqrt(a.m2 / float64(a.count-1))
+	}
+
+	return s
+}
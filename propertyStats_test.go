@@ -0,0 +1,52 @@
+package keyize
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPropertyStats(t *testing.T) {
+	// Extraction
+
+	rec := typeKeys('a', 'b', 'a', 'a')
+
+	rec.Events[7].At = 40
+
+	props := rec.DynamicsWithOptions(&ExtractOptions{Stats: true}).Properties()
+
+	stats := props["D.a"].Stats
+
+	if stats == nil || stats.Count != 3 || stats.Min != 5 || stats.Max != 10 || stats.Median != 5 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if math.Abs(stats.StdDev-math.Sqrt(25.0/3)) > 1e-9 {
+		t.Fatalf("unexpected standard deviation %f", stats.StdDev)
+	}
+
+	if rec.Dynamics().Properties()["D.a"].Stats != nil {
+		t.Fatal("stats set without ExtractOptions.Stats")
+	}
+
+	// The estimated median of many values is close to the exact median, and the mean is stable despite a large offset
+
+	rng := rand.New(rand.NewSource(1))
+	a := &statsAccumulator{}
+	values := make([]float64, 10000)
+
+	for i := range values {
+		values[i] = 1e9 + rng.ExpFloat64()*100
+		a.add(values[i])
+	}
+
+	stats = a.stats()
+
+	if exact := median(values); math.Abs(stats.Median-exact) > 2 {
+		t.Fatalf("estimated median %f is far from %f", stats.Median, exact)
+	}
+
+	if math.Abs(a.mean-1e9-100) > 5 || math.Abs(stats.StdDev-100) > 5 {
+		t.Fatalf("unexpected mean %f or standard deviation %f", a.mean, stats.StdDev)
+	}
+}
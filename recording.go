@@ -1,10 +1,9 @@
 package keyize
 
 import (
-	"errors"
-	"regexp"
 	"strconv"
-	"unicode/utf8"
+	"time"
+	"unicode"
 )
 
 type RawEventKind int
@@ -14,16 +13,27 @@ const (
 	KeyUp
 )
 
-var runeEventKindMap = map[rune]RawEventKind{
-	'u': KeyUp,
-	'd': KeyDown,
+var rawEventKindNames = map[RawEventKind]string{
+	KeyDown: "KeyDown",
+	KeyUp:   "KeyUp",
 }
 
-var v1regex = regexp.MustCompile("([a-z])(.|\n)([0-9]+)")
+// String returns the name of RawEventKind k, such as "KeyDown".
+func (k RawEventKind) String() string {
+	if name, ok := rawEventKindNames[k]; ok {
+		return name
+	}
+
+	return "RawEventKind(" + strconv.Itoa(int(k)) + ")"
+}
 
 // Recording represents a user's raw typing recording
 type Recording struct {
 	Events []*RecordingEvent
+
+	// Resolution is the duration represented by one unit of RecordingEvent.At.
+	// The zero value represents millisecond resolution, as used by KeyizeV1.
+	Resolution time.Duration
 }
 
 // RecordingEvent is a specific event which took place during a recording
@@ -31,195 +41,195 @@ type RecordingEvent struct {
 	At      int
 	Kind    RawEventKind
 	Subject rune
-}
 
-// Text returns the (approximate) text typed in Recording r.
-func (r *Recording) Text() string {
-	var text []rune
+	// Code is the physical key which caused the event, using W3C UI Events code values such as "KeyA", if known
+	Code string
 
-	for _, e := range r.Events {
-		if e.Kind == KeyDown {
-			// Check if this is a special deletion character
-			// We cannot do much with delete (\x7F) because we don't know cursor location when it is being used. Oh well.
+	// Repeat indicates a KeyDown generated by auto-repeat while the key was held, rather than by a press
+	Repeat bool
+}
 
-			if e.Subject == '\b' {
-				// Backspace. Delete last character.
-				text = text[:len(text)-1]
+// RepeatPolicy determines how auto-repeat KeyDowns are handled by Dynamics extraction and text reconstruction.
+//
+// Auto-repeats are KeyDowns with Repeat set, and KeyDowns of a subject which is already held and was pressed last
+// (see MarkRepeats). They are dropped by default during Dynamics extraction, and kept during text reconstruction,
+// where each one edits the text.
+type RepeatPolicy int
 
-				continue
-			}
+const (
+	// DropRepeats ignores auto-repeats, so a held key is treated as a single press
+	DropRepeats RepeatPolicy = iota
 
-			// Otherwise append normally
-			text = append(text, e.Subject)
-		}
+	// KeepRepeats treats auto-repeats as ordinary KeyDowns, as they were treated before repeats were detected
+	KeepRepeats
+)
+
+func (r *Recording) resolution() time.Duration {
+	if r.Resolution <= 0 {
+		return time.Millisecond
 	}
 
-	return string(text)
+	return r.Resolution
 }
 
-// Dynamics converts the raw data from Recording r to Dynamics d by extracting and averaging timings.
-func (r *Recording) Dynamics() *Dynamics {
-	// Create propTimings (prop -> timings slice)
-	// First rune of key: a = DownDown, b = UpDown, c = Dwell
+// millis converts a count of At units in Recording r to milliseconds.
+func (r *Recording) millis(units int) float64 {
+	if r.Resolution <= 0 || r.Resolution == time.Millisecond {
+		return float64(units)
+	}
 
-	propTimings := newFloatSliceMapMan()
+	return float64(units) * float64(r.Resolution) / float64(time.Millisecond)
+}
 
-	var lastDown rune
-	var lastDownTime int
+// repeats reports which events of Recording r are auto-repeats: KeyDowns with Repeat set,
+// and KeyDowns of a subject which is held and was also the subject of the previous KeyDown.
+//
+// Auto-repeat stops when another key is pressed, so a KeyDown of a held subject following a KeyDown of another subject
+// is a new press whose previous KeyUp was lost, not an auto-repeat.
+func (r *Recording) repeats() []bool {
+	repeats := make([]bool, len(r.Events))
+	held := map[holdKey]bool{}
+
+	isHeld := func(h holdKey) bool {
+		return held[h]
+	}
 
-	var lastUp rune
-	var lastUpTime int
+	hasLastDown := false
+	var lastDown holdKey
 
 	for i, e := range r.Events {
 		switch e.Kind {
 		case KeyDown:
-			// DownDown / DD prop
-
-			// => If last down was not zero value rune '\x00', push DD timing
-			if lastDown != '\x00' {
-				ddEventName := "a" + string(lastDown) + string(e.Subject)
-
-				propTimings.Add(ddEventName, float64(e.At-lastDownTime))
-			}
-
-			// UpDown / UD prop
-
-			// => If last up was not zero value rune '\x00', push UD timing
-			if lastUp != '\x00' {
-				udEventName := "b" + string(lastUp) + string(e.Subject)
-
-				propTimings.Add(udEventName, float64(e.At-lastUpTime))
-			}
+			h := newHoldKey(e, e.Subject)
 
-			// Update lastDown
+			repeats[i] = e.Repeat || (held[h] && hasLastDown && lastDown == h)
+			held[h] = true
 
-			lastDown = e.Subject
-			lastDownTime = e.At
+			hasLastDown = true
+			lastDown = h
 		case KeyUp:
-			// Dwell prop
-
-			// => Seek to last keydown for e.Subject if exists
-			for b := i; b >= 0; b-- {
-				relevantPreviousEvent := r.Events[b]
-
-				if relevantPreviousEvent.Kind == KeyDown && relevantPreviousEvent.Subject == e.Subject {
-					// Push timing and exit seeking
-
-					dwellEventName := "c" + string(e.Subject)
-
-					propTimings.Add(dwellEventName, float64(e.At-relevantPreviousEvent.At))
-
-					break
-				}
+			if h, ok := releasedHoldKey(e, e.Subject, isHeld); ok {
+				delete(held, h)
 			}
-
-			// Update lastUp
-
-			lastUp = e.Subject
-			lastUpTime = e.At
 		}
 	}
 
-	// Average propTimings
-
-	avgPropTimings := propTimings.Reduce()
-
-	// Create the Dynamics and convert avgPropTimings timings to DynamicsProperties
-
-	d := NewDynamics()
-
-	for propKey, avgTime := range avgPropTimings {
-		// It may be assumed that first rune is valid because it was created above
-
-		propTypeRune, _ := utf8.DecodeRuneInString(propKey)
+	return repeats
+}
 
-		switch propTypeRune {
-		case 'a':
-			// DownDown
+// holdKey identifies a held key, so each KeyUp may be paired with the KeyDown of the same key.
+type holdKey struct {
+	code string
+	key  rune
+}
 
-			fallthrough
-		case 'b':
-			// UpDown
+// newHoldKey returns the holdKey of RecordingEvent e, whose key is k.
+// Events are identified by their Code when known, as it is the same for the press and release of a key
+// even when their subjects differ.
+func newHoldKey(e *RecordingEvent, k rune) holdKey {
+	if e.Code != "" {
+		return holdKey{code: e.Code}
+	}
 
-			rune1, _ := utf8.DecodeRuneInString(propKey[1:])
-			rune2, _ := utf8.DecodeRuneInString(propKey[2:])
+	return holdKey{key: k}
+}
 
-			propKind := UpDown
+// releasedHoldKey returns the holdKey of the key released by KeyUp e, whose key is k, using held to find which keys are held.
+//
+// Browsers report the key of a release as it is when released, so a letter released after Shift has a subject of
+// another case than its press (eg. 'A' then 'a'). A KeyUp matching no held key is paired with a held key of the same
+// letter in another case, so the press is not left held.
+func releasedHoldKey(e *RecordingEvent, k rune, held func(holdKey) bool) (holdKey, bool) {
+	if h := newHoldKey(e, k); held(h) {
+		return h, true
+	}
 
-			if propTypeRune == 'a' {
-				propKind = DownDown
-			}
+	if h := (holdKey{key: k}); held(h) {
+		return h, true
+	}
 
-			d.AddProperty(&DynamicsProperty{
-				Kind:  propKind,
-				KeyA:  rune1,
-				KeyB:  rune2,
-				Value: avgTime,
-			})
-		case 'c':
-			// Dwell
-
-			rune1, _ := utf8.DecodeRuneInString(propKey[1:])
-
-			d.AddProperty(&DynamicsProperty{
-				Kind:  Dwell,
-				KeyA:  rune1,
-				KeyB:  '\x00',
-				Value: avgTime,
-			})
+	for f := unicode.SimpleFold(k); f != k; f = unicode.SimpleFold(f) {
+		if h := (holdKey{key: f}); held(h) {
+			return h, true
 		}
 	}
 
-	return d
+	return holdKey{}, false
 }
 
-// ImportKeyizeV1 imports a keystroke recording of the Keyize V1 format.
-// These recordings may be generated from users by a corresponding recording library.
-func ImportKeyizeV1(d string) (*Recording, error) {
-	rec := &Recording{
-		Events: []*RecordingEvent{},
+// MarkRepeats sets Repeat on each KeyDown of Recording r which is an auto-repeat.
+//
+// Not every source flags auto-repeats (eg. KeyizeV1 recordings made by the web-recorder), so a KeyDown of a subject
+// which has been pressed and not yet released, with no other KeyDown since, is taken to be one.
+func (r *Recording) MarkRepeats() {
+	for i, repeat := range r.repeats() {
+		if repeat {
+			r.Events[i].Repeat = true
+		}
 	}
+}
 
-	matches := v1regex.FindAllStringSubmatch(d, -1)
-
-	var lastAt int64 = -100
-
-	for _, m := range matches {
-		kindRune, _ := utf8.DecodeRuneInString(m[1])
-		subjectRune, _ := utf8.DecodeRuneInString(m[2])
+// Text returns the text typed in Recording r.
+//
+// It is the Text of r.Reconstruct(), so deletions, caret movement and auto-repeats are taken into account.
+func (r *Recording) Text() string {
+	return r.Reconstruct().Text
+}
 
-		if kindRune == utf8.RuneError || subjectRune == utf8.RuneError {
-			return nil, errors.New("invalid subject or kind rune")
-		}
+// Dynamics converts the raw data from Recording r to Dynamics d by extracting and averaging timings.
+//
+// Properties are keyed by the subject (the produced character) of each event. Auto-repeats are dropped.
+func (r *Recording) Dynamics() *Dynamics {
+	return r.DynamicsWithOptions(nil)
+}
 
-		eventKind, ok := runeEventKindMap[kindRune]
+// DynamicsWithRepeatPolicy is like Dynamics, but handles auto-repeats according to policy.
+//
+// With KeepRepeats, each auto-repeat produces a DownDown property from the key to itself, and the Dwell of the key
+// is measured from its last auto-repeat.
+func (r *Recording) DynamicsWithRepeatPolicy(policy RepeatPolicy) *Dynamics {
+	return r.DynamicsWithOptions(&ExtractOptions{
+		Repeats: policy,
+	})
+}
 
-		if !ok {
-			return nil, errors.New("invalid event kind " + string(kindRune))
-		}
+// PhysicalDynamics converts the raw data from Recording r to Dynamics d by extracting and averaging timings.
+//
+// Properties are keyed by the physical key of each event, so left and right modifiers or numpad and main-row digits
+// produce distinct properties (eg. "D.{ShiftLeft}", "DD.{Numpad1}.{Digit2}").
+// Events without a Code, or with a Code unknown to PhysicalKeyRune, are keyed by their subject.
+func (r *Recording) PhysicalDynamics() *Dynamics {
+	return r.DynamicsWithOptions(&ExtractOptions{
+		Physical: true,
+	})
+}
 
-		at, err := strconv.ParseInt(m[3], 10, 64)
+// eventSubject returns the subject of RecordingEvent e.
+func eventSubject(e *RecordingEvent) rune {
+	return e.Subject
+}
 
-		if err != nil {
-			return nil, err
-		}
+// eventPhysicalKey returns the physical key rune of RecordingEvent e, or its subject if unknown.
+func eventPhysicalKey(e *RecordingEvent) rune {
+	if r, ok := PhysicalKeyRune(e.Code); ok {
+		return r
+	}
 
-		if at < lastAt {
-			return nil, errors.New("invalid at value " + strconv.Itoa(int(at)) + " is less than previous")
-		}
+	return e.Subject
+}
 
-		if at < 0 {
-			return nil, errors.New("invalid at value " + strconv.Itoa(int(at)) + " is less than 0")
-		}
+// DynamicsWithOptions converts the raw data from Recording r to Dynamics d by extracting and averaging timings
+// according to ExtractOptions opts. If opts is nil, the zero value is used.
+func (r *Recording) DynamicsWithOptions(opts *ExtractOptions) *Dynamics {
+	if opts == nil {
+		opts = &ExtractOptions{}
+	}
 
-		lastAt = at
+	x := newDynamicsExtractor(r, opts)
 
-		rec.Events = append(rec.Events, &RecordingEvent{
-			Kind:    eventKind,
-			At:      int(at),
-			Subject: subjectRune,
-		})
+	for i, repeat := range r.repeats() {
+		x.add(r.Events[i], repeat)
 	}
 
-	return rec, nil
+	return x.dynamics()
 }
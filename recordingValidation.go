@@ -0,0 +1,384 @@
+package keyize
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+type AnomalyKind int
+
+const (
+	// OrphanKeyUp is a KeyUp without a preceding KeyDown of the same subject
+	OrphanKeyUp AnomalyKind = iota
+
+	// UnreleasedKeyDown is a KeyDown which is not followed by a KeyUp of the same subject before the recording ends
+	// or the subject is pressed again (other than by auto-repeat)
+	UnreleasedKeyDown
+
+	// DuplicateEvent is an event identical to an earlier event, including its At value
+	DuplicateEvent
+
+	// ZeroDwell is a KeyDown and KeyUp pair with equal At values
+	ZeroDwell
+
+	// OutOfOrderEvent is an event with an At value less than that of the previous event
+	OutOfOrderEvent
+)
+
+var anomalyKindNames = map[AnomalyKind]string{
+	OrphanKeyUp:       "OrphanKeyUp",
+	UnreleasedKeyDown: "UnreleasedKeyDown",
+	DuplicateEvent:    "DuplicateEvent",
+	ZeroDwell:         "ZeroDwell",
+	OutOfOrderEvent:   "OutOfOrderEvent",
+}
+
+// String returns the name of AnomalyKind k, such as "OrphanKeyUp".
+func (k AnomalyKind) String() string {
+	if name, ok := anomalyKindNames[k]; ok {
+		return name
+	}
+
+	return "AnomalyKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Anomaly is a data quality problem found in a Recording.
+type Anomaly struct {
+	Kind AnomalyKind
+
+	// Indices holds the indices in Recording.Events of the events involved.
+	// For ZeroDwell, they are the KeyDown and KeyUp. For DuplicateEvent, they are the original and the duplicate.
+	Indices []int
+}
+
+func (a *Anomaly) String() string {
+	s := a.Kind.String() + " at events"
+
+	for _, i := range a.Indices {
+		s += " " + strconv.Itoa(i)
+	}
+
+	return s
+}
+
+// pressPair is a KeyDown and its matching KeyUp, as indices in Recording.Events.
+type pressPair struct {
+	down int
+	up   int
+}
+
+// inspect finds the anomalies in Recording r, in order of the event at which they are detected,
+// as well as the KeyDown and KeyUp pairs which were matched.
+func (r *Recording) inspect() (anomalies []*Anomaly, pairs []pressPair) {
+	// held maps held keys to the index of the KeyDown which pressed them
+	held := map[holdKey]int{}
+
+	isHeld := func(h holdKey) bool {
+		_, ok := held[h]
+
+		return ok
+	}
+
+	repeats := r.repeats()
+
+	// sameAt maps events to their index, for events sharing the At value of the current event
+	sameAt := map[RecordingEvent]int{}
+
+	lastAt := 0
+
+	for i, e := range r.Events {
+		if i > 0 && e.At < lastAt {
+			anomalies = append(anomalies, &Anomaly{
+				Kind:    OutOfOrderEvent,
+				Indices: []int{i},
+			})
+		}
+
+		if i == 0 || e.At != lastAt {
+			sameAt = map[RecordingEvent]int{}
+		}
+
+		lastAt = e.At
+
+		if original, ok := sameAt[*e]; ok {
+			anomalies = append(anomalies, &Anomaly{
+				Kind:    DuplicateEvent,
+				Indices: []int{original, i},
+			})
+
+			continue
+		}
+
+		sameAt[*e] = i
+
+		switch e.Kind {
+		case KeyDown:
+			h := newHoldKey(e, e.Subject)
+
+			if down, ok := held[h]; ok {
+				if repeats[i] {
+					// An auto-repeat is part of the held press
+					continue
+				}
+
+				// The key was pressed again, so its KeyUp was lost
+
+				anomalies = append(anomalies, &Anomaly{
+					Kind:    UnreleasedKeyDown,
+					Indices: []int{down},
+				})
+			}
+
+			held[h] = i
+		case KeyUp:
+			h, ok := releasedHoldKey(e, e.Subject, isHeld)
+
+			if !ok {
+				anomalies = append(anomalies, &Anomaly{
+					Kind:    OrphanKeyUp,
+					Indices: []int{i},
+				})
+
+				continue
+			}
+
+			down := held[h]
+
+			delete(held, h)
+
+			if r.Events[down].At == e.At {
+				anomalies = append(anomalies, &Anomaly{
+					Kind:    ZeroDwell,
+					Indices: []int{down, i},
+				})
+			}
+
+			pairs = append(pairs, pressPair{
+				down: down,
+				up:   i,
+			})
+		}
+	}
+
+	// Remaining held keys were never released
+
+	var unreleased []int
+
+	for _, down := range held {
+		unreleased = append(unreleased, down)
+	}
+
+	sort.Ints(unreleased)
+
+	for _, down := range unreleased {
+		anomalies = append(anomalies, &Anomaly{
+			Kind:    UnreleasedKeyDown,
+			Indices: []int{down},
+		})
+	}
+
+	return anomalies, pairs
+}
+
+// Validate reports every anomaly found in Recording r. It returns nil if none are found.
+//
+// Keys are identified by Code when known, or else by subject, and a KeyUp of a subject in another case than its KeyDown
+// (eg. after Shift is released) releases it. Anomalies are ordered by the event at which they were detected,
+// followed by any UnreleasedKeyDown anomalies of keys still held at the end of the recording.
+func (r *Recording) Validate() []*Anomaly {
+	anomalies, _ := r.inspect()
+
+	return anomalies
+}
+
+// RepairPolicy determines which anomalies are repaired by Repair.
+type RepairPolicy struct {
+	// SortEvents stably sorts events by At, repairing OutOfOrderEvent anomalies
+	SortEvents bool
+
+	// Dedupe drops DuplicateEvent anomalies
+	Dedupe bool
+
+	// DropOrphanKeyUps drops OrphanKeyUp anomalies
+	DropOrphanKeyUps bool
+
+	// DropZeroDwells drops both events of ZeroDwell anomalies
+	DropZeroDwells bool
+
+	// SynthesizeKeyUps adds a KeyUp for each UnreleasedKeyDown, SynthesizedDwell after the press or its last auto-repeat,
+	// and before the key is pressed again
+	SynthesizeKeyUps bool
+
+	// SynthesizedDwell is the dwell of synthesized KeyUps. If zero, the mean dwell of the recording is used,
+	// or 100ms if it has no complete key presses.
+	SynthesizedDwell time.Duration
+}
+
+// DefaultRepairPolicy repairs every kind of anomaly.
+var DefaultRepairPolicy = RepairPolicy{
+	SortEvents:       true,
+	Dedupe:           true,
+	DropOrphanKeyUps: true,
+	DropZeroDwells:   true,
+	SynthesizeKeyUps: true,
+}
+
+// Repair returns a copy of Recording r with anomalies repaired according to policy. r is not modified.
+func Repair(r *Recording, policy RepairPolicy) *Recording {
+	repaired := &Recording{
+		Events:     make([]*RecordingEvent, len(r.Events)),
+		Resolution: r.Resolution,
+	}
+
+	for i, e := range r.Events {
+		c := *e
+		repaired.Events[i] = &c
+	}
+
+	if policy.SortEvents {
+		sort.SliceStable(repaired.Events, func(i, j int) bool {
+			return repaired.Events[i].At < repaired.Events[j].At
+		})
+	}
+
+	// Drop events
+
+	anomalies, _ := repaired.inspect()
+
+	drop := map[int]bool{}
+
+	for _, a := range anomalies {
+		switch {
+		case a.Kind == DuplicateEvent && policy.Dedupe:
+			drop[a.Indices[1]] = true
+		case a.Kind == OrphanKeyUp && policy.DropOrphanKeyUps:
+			drop[a.Indices[0]] = true
+		case a.Kind == ZeroDwell && policy.DropZeroDwells:
+			drop[a.Indices[0]] = true
+			drop[a.Indices[1]] = true
+		}
+	}
+
+	if len(drop) > 0 {
+		kept := repaired.Events[:0]
+
+		for i, e := range repaired.Events {
+			if !drop[i] {
+				kept = append(kept, e)
+			}
+		}
+
+		repaired.Events = kept
+	}
+
+	if policy.SynthesizeKeyUps {
+		repaired.synthesizeKeyUps(policy.SynthesizedDwell)
+	}
+
+	return repaired
+}
+
+// synthesizeKeyUps adds a KeyUp for each unreleased KeyDown in Recording r.
+func (r *Recording) synthesizeKeyUps(dwell time.Duration) {
+	anomalies, pairs := r.inspect()
+	repeats := r.repeats()
+
+	// Determine dwell in units of At
+
+	var dwellUnits int
+
+	if dwell > 0 {
+		dwellUnits = int(dwell / r.resolution())
+	} else if len(pairs) > 0 {
+		total := 0
+
+		for _, p := range pairs {
+			total += r.Events[p.up].At - r.Events[p.down].At
+		}
+
+		dwellUnits = total / len(pairs)
+	} else {
+		dwellUnits = int(100 * time.Millisecond / r.resolution())
+	}
+
+	// ups maps insertion indices to the KeyUps to insert there
+	ups := map[int][]*RecordingEvent{}
+
+	var indices []int
+
+	for _, a := range anomalies {
+		if a.Kind != UnreleasedKeyDown {
+			continue
+		}
+
+		down := r.Events[a.Indices[0]]
+		h := newHoldKey(down, down.Subject)
+
+		last := a.Indices[0]
+
+		// Later auto-repeats of the key are part of the same press, which ends before the key is pressed again
+
+		limit := len(r.Events)
+
+		for i := last + 1; i < len(r.Events); i++ {
+			if e := r.Events[i]; e.Kind == KeyDown && newHoldKey(e, e.Subject) == h {
+				if !repeats[i] {
+					limit = i
+
+					break
+				}
+
+				last = i
+			}
+		}
+
+		at := r.Events[last].At + dwellUnits
+
+		if limit < len(r.Events) && at > r.Events[limit].At {
+			at = r.Events[limit].At
+		}
+
+		// Insert before the first later event
+
+		idx := last + 1
+
+		for idx < limit && r.Events[idx].At <= at {
+			idx++
+		}
+
+		if _, ok := ups[idx]; !ok {
+			indices = append(indices, idx)
+		}
+
+		ups[idx] = append(ups[idx], &RecordingEvent{
+			At:      at,
+			Kind:    KeyUp,
+			Subject: down.Subject,
+			Code:    down.Code,
+		})
+	}
+
+	if len(indices) == 0 {
+		return
+	}
+
+	sort.Ints(indices)
+
+	events := make([]*RecordingEvent, 0, len(r.Events)+len(anomalies))
+	last := 0
+
+	for _, idx := range indices {
+		at := ups[idx]
+
+		sort.SliceStable(at, func(i, j int) bool {
+			return at[i].At < at[j].At
+		})
+
+		events = append(events, r.Events[last:idx]...)
+		events = append(events, ups[idx]...)
+		last = idx
+	}
+
+	r.Events = append(events, r.Events[last:]...)
+}
@@ -0,0 +1,124 @@
+package keyize
+
+import (
+	"testing"
+	"time"
+)
+
+// anomalyRecording has, in order: a duplicate, a zero dwell, an orphan key up, an unreleased key down,
+// and an out of order event.
+func anomalyRecording() *Recording {
+	return &Recording{
+		Events: []*RecordingEvent{
+			{At: 0, Kind: KeyDown, Subject: 'a'},
+			{At: 0, Kind: KeyDown, Subject: 'a'},
+			{At: 10, Kind: KeyUp, Subject: 'a'},
+			{At: 20, Kind: KeyDown, Subject: 'b'},
+			{At: 20, Kind: KeyUp, Subject: 'b'},
+			{At: 30, Kind: KeyUp, Subject: 'c'},
+			{At: 40, Kind: KeyDown, Subject: 'd'},
+			{At: 60, Kind: KeyDown, Subject: 'e'},
+			{At: 50, Kind: KeyUp, Subject: 'e'},
+		},
+	}
+}
+
+func TestRecording_Validate(t *testing.T) {
+	if anomalies := typeKeys('a', 'b', 'c').Validate(); anomalies != nil {
+		t.Fatalf("unexpected anomalies %v", anomalies)
+	}
+
+	expected := []string{
+		"DuplicateEvent at events 0 1",
+		"ZeroDwell at events 3 4",
+		"OrphanKeyUp at events 5",
+		"OutOfOrderEvent at events 8",
+		"UnreleasedKeyDown at events 6",
+	}
+
+	anomalies := anomalyRecording().Validate()
+
+	if len(anomalies) != len(expected) {
+		t.Fatalf("unexpected anomalies %v", anomalies)
+	}
+
+	for i, a := range anomalies {
+		if a.String() != expected[i] {
+			t.Fatalf("unexpected anomaly %d %q, expected %q", i, a, expected[i])
+		}
+	}
+}
+
+func TestRepair(t *testing.T) {
+	rec := anomalyRecording()
+
+	repaired := Repair(rec, DefaultRepairPolicy)
+
+	if len(rec.Events) != 9 || rec.Events[7].At != 60 {
+		t.Fatal("recording modified")
+	}
+
+	if anomalies := repaired.Validate(); anomalies != nil {
+		t.Fatalf("unexpected anomalies after repair %v", anomalies)
+	}
+
+	// Sorting e makes its key up an orphan, so d and e are released after the mean dwell of 10
+
+	expected := "da0ua10dd40ud50de60ue70"
+
+	if exported, _ := repaired.ExportKeyizeV1(); exported != expected {
+		t.Fatalf("unexpected repair %q, expected %q", exported, expected)
+	}
+
+	// Synthesized key ups follow the last key down of the subject
+
+	rec = &Recording{
+		Events: []*RecordingEvent{
+			{At: 0, Kind: KeyDown, Subject: 'a'},
+			{At: 10, Kind: KeyDown, Subject: 'b'},
+			{At: 20, Kind: KeyDown, Subject: 'b'},
+			{At: 30, Kind: KeyUp, Subject: 'a'},
+			{At: 90, Kind: KeyDown, Subject: 'c'},
+		},
+	}
+
+	repaired = Repair(rec, RepairPolicy{SynthesizeKeyUps: true, SynthesizedDwell: 50 * time.Millisecond})
+
+	if exported, _ := repaired.ExportKeyizeV1(); exported != "da0db10db20ua30ub70dc90uc140" {
+		t.Fatalf("unexpected repair %q", exported)
+	}
+}
+
+func TestRecording_Validate_lostKeyUp(t *testing.T) {
+	// The KeyUp of the first 'a' is lost before 'a' is pressed again
+
+	rec, err := ImportKeyizeV1("da0db10ub20da30ua40")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anomalies := rec.Validate()
+
+	if len(anomalies) != 1 || anomalies[0].String() != "UnreleasedKeyDown at events 0" {
+		t.Fatalf("unexpected anomalies %v", anomalies)
+	}
+
+	// The KeyUp is inserted before the second press, after the mean dwell of 10
+
+	repaired := Repair(rec, DefaultRepairPolicy)
+
+	if exported, _ := repaired.ExportKeyizeV1(); exported != "da0db10ua10ub20da30ua40" {
+		t.Fatalf("unexpected repair %q", exported)
+	}
+
+	if anomalies := repaired.Validate(); anomalies != nil {
+		t.Fatalf("unexpected anomalies after repair %v", anomalies)
+	}
+
+	// Auto-repeats are not re-presses
+
+	if anomalies := heldRecording().Validate(); anomalies != nil {
+		t.Fatalf("unexpected anomalies %v", anomalies)
+	}
+}
@@ -0,0 +1,115 @@
+package keyize
+
+import (
+	"testing"
+)
+
+// heldRecording holds 'a' for 600 units with two unflagged auto-repeats, then types 'b'.
+func heldRecording() *Recording {
+	return &Recording{
+		Events: []*RecordingEvent{
+			{At: 0, Kind: KeyDown, Subject: 'a'},
+			{At: 500, Kind: KeyDown, Subject: 'a'},
+			{At: 530, Kind: KeyDown, Subject: 'a'},
+			{At: 600, Kind: KeyUp, Subject: 'a'},
+			{At: 700, Kind: KeyDown, Subject: 'b'},
+			{At: 780, Kind: KeyUp, Subject: 'b'},
+		},
+	}
+}
+
+func TestRecording_MarkRepeats(t *testing.T) {
+	rec := heldRecording()
+
+	rec.MarkRepeats()
+
+	for i, e := range rec.Events {
+		if e.Repeat != (i == 1 || i == 2) {
+			t.Fatalf("unexpected Repeat for event %d", i)
+		}
+	}
+
+	if anomalies := rec.Validate(); anomalies != nil {
+		t.Fatalf("unexpected anomalies %v", anomalies)
+	}
+}
+
+func TestRecording_DynamicsWithRepeatPolicy(t *testing.T) {
+	props := heldRecording().Dynamics().Properties()
+
+	if _, ok := props["DD.a.a"]; ok {
+		t.Fatal("auto-repeat produced a DownDown property")
+	}
+
+	if props["D.a"].Value != 600 || props["DD.a.b"].Value != 700 {
+		t.Fatalf("unexpected properties %v %v", props["D.a"], props["DD.a.b"])
+	}
+
+	props = heldRecording().DynamicsWithRepeatPolicy(KeepRepeats).Properties()
+
+	if props["DD.a.a"].Value != 265 || props["D.a"].Value != 70 {
+		t.Fatalf("unexpected properties %v %v", props["DD.a.a"], props["D.a"])
+	}
+
+	// Text
+
+	if text := heldRecording().Text(); text != "aaab" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	if text := heldRecording().ReconstructWithRepeatPolicy(DropRepeats).Text; text != "ab" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	// Each auto-repeat of a held Backspace deletes another rune, whether flagged or detected
+
+	rec, err := ImportKeyizeV1("da0ua10db20ub30dc40uc50d[Backspace]100d[Backspace]600r[Backspace]630u[Backspace]650")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text := rec.Text(); text != "" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	if text := rec.ReconstructWithRepeatPolicy(DropRepeats).Text; text != "ab" {
+		t.Fatalf("unexpected text %q", text)
+	}
+}
+
+func TestRecording_repeats(t *testing.T) {
+	// The KeyUp of the first 'a' is lost, so 'a' is still held when pressed again, but 'b' was pressed in between
+
+	rec, err := ImportKeyizeV1("da0db10ub20da30ua40db50ub60")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text := rec.Text(); text != "abab" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	if props := rec.Dynamics().Properties(); props["DD.b.a"] == nil || props["DD.a.b"].Value != 15 {
+		t.Fatalf("re-press after lost KeyUp was dropped %v", props)
+	}
+
+	// Shift is released before the letter, so the KeyUp of 'A' is reported as 'a'
+
+	rec, err = ImportKeyizeV1("d[Shift]0dA10u[Shift]20ua30d[Shift]100dA110u[Shift]120ua130")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text := rec.Text(); text != "AA" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	for i, repeat := range rec.repeats() {
+		if repeat {
+			t.Fatalf("event %d detected as auto-repeat", i)
+		}
+	}
+}
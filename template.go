@@ -0,0 +1,163 @@
+package keyize
+
+import (
+	"math"
+	"sort"
+)
+
+// Template is a typing profile which is updated incrementally with each new Dynamics of a user,
+// such as after each successful login, so it adapts to gradual drift in their typing without keeping past Dynamics.
+//
+// Recent Dynamics are weighted according to LearningRate or Window. With neither set, every Dynamics is weighted
+// equally, and the value and standard deviation of each property are those of the AvgDynamics of every absorbed Dynamics.
+type Template struct {
+	// LearningRate, if not zero, is the weight (0 to 1) given to each new value of a property, so the weight of older
+	// values decays exponentially. Until a property has absorbed 1/LearningRate values, they are weighted equally.
+	LearningRate float64
+
+	// Window, if not zero and LearningRate is zero, is equivalent to a LearningRate of 1/Window. The weighting is
+	// exponential rather than a sliding window, so the weight of a value halves after roughly 0.7*Window newer values.
+	Window int
+
+	properties map[string]*templateProperty
+}
+
+// templateProperty is the state of a property of a Template.
+type templateProperty struct {
+	prop *DynamicsProperty
+
+	count int
+
+	// mean, variance and meanAbsDev are exponentially weighted. variance is the weighted population variance.
+	mean       float64
+	variance   float64
+	meanAbsDev float64
+
+	// sumSqWeights is the sum of the squares of the weights of the values, which is 1/count if they are weighted equally.
+	// 1/sumSqWeights is the effective sample size, used to correct the bias of variance.
+	sumSqWeights float64
+
+	min float64
+	max float64
+}
+
+// NewTemplate returns a new, empty Template which weights every Dynamics equally.
+// Set LearningRate or Window before the first Update to weight recent Dynamics more heavily.
+func NewTemplate() *Template {
+	return &Template{
+		properties: map[string]*templateProperty{},
+	}
+}
+
+// rate returns the weight given to the count-th value of a property.
+func (t *Template) rate(count int) float64 {
+	rate := 1 / float64(count)
+
+	if t.LearningRate > 0 {
+		return math.Max(rate, t.LearningRate)
+	}
+
+	if t.Window > 0 && count > t.Window {
+		return 1 / float64(t.Window)
+	}
+
+	return rate
+}
+
+// Update absorbs the properties of Dynamics d into Template t, in time proportional to the number of properties of d.
+func (t *Template) Update(d *Dynamics) {
+	if t.properties == nil {
+		t.properties = map[string]*templateProperty{}
+	}
+
+	for name, p := range d.properties {
+		tp, ok := t.properties[name]
+
+		if !ok {
+			prop := *p
+			prop.Stats = nil
+
+			tp = &templateProperty{
+				prop: &prop,
+				min:  p.Value,
+				max:  p.Value,
+			}
+
+			t.properties[name] = tp
+		}
+
+		tp.count++
+
+		// Exponentially weighted mean and variance, which are the ordinary mean and population variance when rate is 1/count
+
+		rate := t.rate(tp.count)
+		delta := p.Value - tp.mean
+
+		tp.mean += rate * delta
+		tp.variance = (1 - rate) * (tp.variance + rate*delta*delta)
+		tp.meanAbsDev += rate * (math.Abs(p.Value-tp.mean) - tp.meanAbsDev)
+		tp.sumSqWeights = (1-rate)*(1-rate)*tp.sumSqWeights + rate*rate
+
+		tp.min = math.Min(tp.min, p.Value)
+		tp.max = math.Max(tp.max, p.Value)
+	}
+}
+
+// Count returns the number of values absorbed for the property with name name.
+func (t *Template) Count(name string) int {
+	if tp, ok := t.properties[name]; ok {
+		return tp.count
+	}
+
+	return 0
+}
+
+// Dynamics returns a new Dynamics holding the current value of each property of Template t.
+//
+// The Stats of each property hold the number of values absorbed, the exponentially weighted sample standard deviation
+// (corrected for bias using the effective sample size) and mean absolute deviation, and the minimum and maximum values.
+// The mean absolute deviation is measured from the mean as of each value, so it differs from that of AvgDynamics,
+// and the minimum and maximum cover every value absorbed, as they are not forgotten. The median is not tracked and is 0.
+func (t *Template) Dynamics() *Dynamics {
+	d := NewDynamics()
+
+	for _, tp := range t.properties {
+		prop := *tp.prop
+
+		prop.Value = tp.mean
+		prop.Stats = &PropertyStats{
+			Count:      tp.count,
+			StdDev:     tp.stdDev(),
+			Min:        tp.min,
+			Max:        tp.max,
+			MeanAbsDev: tp.meanAbsDev,
+		}
+
+		d.AddProperty(&prop)
+	}
+
+	return d
+}
+
+// stdDev returns the exponentially weighted sample standard deviation of templateProperty tp, which is 0 if only one
+// value has been absorbed. With equal weights, it is the ordinary sample standard deviation.
+func (tp *templateProperty) stdDev() float64 {
+	if tp.sumSqWeights >= 1 {
+		return 0
+	}
+
+	return math.Sqrt(tp.variance / (1 - tp.sumSqWeights))
+}
+
+// sortedNames returns the names of the properties of Template t in order.
+func (t *Template) sortedNames() []string {
+	names := make([]string, 0, len(t.properties))
+
+	for name := range t.properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
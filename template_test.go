@@ -0,0 +1,90 @@
+package keyize
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestTemplate_Update(t *testing.T) {
+	var sessions []*Dynamics
+
+	for _, v := range []float64{100, 110, 90, 200, 200, 200} {
+		d := NewDynamics()
+
+		d.AddPropertyByName("DD.t.h", v)
+
+		sessions = append(sessions, d)
+	}
+
+	// Without forgetting, a Template is the average of every Dynamics
+
+	tmpl := NewTemplate()
+
+	for _, d := range sessions {
+		tmpl.Update(d)
+	}
+
+	p := tmpl.Dynamics().Properties()["DD.t.h"]
+	avg := AvgDynamics(sessions).Properties()["DD.t.h"]
+
+	if math.Abs(p.Value-avg.Value) > 1e-9 || p.Stats.Count != 6 || tmpl.Count("DD.t.h") != 6 || p.Stats.Min != 90 || p.Stats.Max != 200 {
+		t.Fatalf("unexpected property %+v %+v", p, p.Stats)
+	}
+
+	// Sample standard deviation, as for AvgDynamics
+
+	if math.Abs(p.Stats.StdDev-avg.Stats.StdDev) > 1e-9 {
+		t.Fatalf("unexpected standard deviation %f", p.Stats.StdDev)
+	}
+
+	// A window of 3 adapts to drift. The first 3 values are weighted equally, after which the distance from each
+	// new value of 200 shrinks by 2/3.
+
+	tmpl = NewTemplate()
+	tmpl.Window = 3
+
+	for _, d := range sessions {
+		tmpl.Update(d)
+	}
+
+	if v := tmpl.Dynamics().Properties()["DD.t.h"].Value; math.Abs(v-(200-100*math.Pow(2.0/3, 3))) > 1e-9 {
+		t.Fatalf("windowed template did not adapt: %f", v)
+	}
+
+	// JSON round-trip
+
+	data, err := json.Marshal(tmpl)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &Template{}
+
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Window != 3 || decoded.Count("DD.t.h") != 6 || decoded.Dynamics().Properties()["DD.t.h"].Value != tmpl.Dynamics().Properties()["DD.t.h"].Value ||
+		decoded.Dynamics().Properties()["DD.t.h"].Stats.StdDev != tmpl.Dynamics().Properties()["DD.t.h"].Stats.StdDev {
+		t.Fatalf("round-trip produced incorrect template %s", data)
+	}
+
+	// Updating continues from the decoded state
+
+	decoded.Update(sessions[0])
+	tmpl.Update(sessions[0])
+
+	if decoded.Dynamics().Properties()["DD.t.h"].Value != tmpl.Dynamics().Properties()["DD.t.h"].Value {
+		t.Fatal("decoded template updated differently")
+	}
+
+	if err := json.Unmarshal([]byte(`{"properties":[{"name":"DD.t.h","count":0,"sumSqWeights":1}]}`), decoded); err == nil {
+		t.Fatal("expected error for invalid count")
+	}
+
+	if err := json.Unmarshal([]byte(`{"properties":[{"name":"DD.t.h","count":1,"mean":100}]}`), decoded); err == nil {
+		t.Fatal("expected error for missing sumSqWeights")
+	}
+}
@@ -0,0 +1,288 @@
+package keyize
+
+import (
+	"unicode"
+)
+
+type EditKind int
+
+const (
+	Insertion EditKind = iota
+	Deletion
+)
+
+// Edit is a change made to the text of a Recording by one of its events.
+type Edit struct {
+	// At is the At value of the event which caused the edit
+	At int
+
+	// EventIndex is the index of the event which caused the edit in Recording.Events
+	EventIndex int
+
+	Kind EditKind
+
+	// Position is the index, in runes, in the text at which Text was inserted or from which it was deleted
+	Position int
+
+	Text string
+}
+
+// TextReconstruction is the result of replaying the events of a Recording against a simulated text field.
+type TextReconstruction struct {
+	// Text is the final text
+	Text string
+
+	// Caret is the final position of the caret, in runes
+	Caret int
+
+	// Edits holds every insertion and deletion, in order
+	Edits []*Edit
+}
+
+// textEditor is a simulated text field with a caret and selection.
+type textEditor struct {
+	text []rune
+
+	// caret is the index of the caret in text. anchor is the other end of the selection, and equals caret when nothing is selected.
+	caret  int
+	anchor int
+
+	// held holds the modifier keys currently held
+	held map[rune]bool
+
+	edits []*Edit
+
+	// event is the event currently being replayed
+	event      *RecordingEvent
+	eventIndex int
+}
+
+func (t *textEditor) selection() (start int, end int) {
+	if t.anchor < t.caret {
+		return t.anchor, t.caret
+	}
+
+	return t.caret, t.anchor
+}
+
+func (t *textEditor) delete(start int, end int) {
+	if start >= end {
+		return
+	}
+
+	t.edits = append(t.edits, &Edit{
+		At:         t.event.At,
+		EventIndex: t.eventIndex,
+		Kind:       Deletion,
+		Position:   start,
+		Text:       string(t.text[start:end]),
+	})
+
+	t.text = append(t.text[:start], t.text[end:]...)
+	t.caret = start
+	t.anchor = start
+}
+
+// deleteSelection deletes the selected text, returning false if nothing is selected.
+func (t *textEditor) deleteSelection() bool {
+	start, end := t.selection()
+
+	if start == end {
+		return false
+	}
+
+	t.delete(start, end)
+
+	return true
+}
+
+func (t *textEditor) insert(r rune) {
+	t.deleteSelection()
+
+	t.edits = append(t.edits, &Edit{
+		At:         t.event.At,
+		EventIndex: t.eventIndex,
+		Kind:       Insertion,
+		Position:   t.caret,
+		Text:       string(r),
+	})
+
+	t.text = append(t.text, 0)
+	copy(t.text[t.caret+1:], t.text[t.caret:])
+	t.text[t.caret] = r
+
+	t.caret++
+	t.anchor = t.caret
+}
+
+// lineStart returns the index of the start of the line containing index i.
+func (t *textEditor) lineStart(i int) int {
+	for i > 0 && t.text[i-1] != '\n' {
+		i--
+	}
+
+	return i
+}
+
+// lineEnd returns the index of the end of the line containing index i.
+func (t *textEditor) lineEnd(i int) int {
+	for i < len(t.text) && t.text[i] != '\n' {
+		i++
+	}
+
+	return i
+}
+
+// move moves the caret to index i. If shift is held, the selection is extended, otherwise it is cleared.
+func (t *textEditor) move(i int) {
+	if i < 0 {
+		i = 0
+	} else if i > len(t.text) {
+		i = len(t.text)
+	}
+
+	t.caret = i
+
+	if !t.held[KeyShift] {
+		t.anchor = i
+	}
+}
+
+// moveLine moves the caret to the same column of the previous (dir -1) or next (dir 1) line.
+func (t *textEditor) moveLine(dir int) {
+	start := t.lineStart(t.caret)
+	column := t.caret - start
+
+	var target int
+
+	if dir < 0 {
+		if start == 0 {
+			t.move(0)
+
+			return
+		}
+
+		target = t.lineStart(start - 1)
+	} else {
+		end := t.lineEnd(t.caret)
+
+		if end == len(t.text) {
+			t.move(end)
+
+			return
+		}
+
+		target = end + 1
+	}
+
+	if targetEnd := t.lineEnd(target); target+column > targetEnd {
+		t.move(targetEnd)
+	} else {
+		t.move(target + column)
+	}
+}
+
+// horizontal moves the caret one rune left (dir -1) or right (dir 1).
+// Without shift, an existing selection is collapsed to its start or end instead.
+func (t *textEditor) horizontal(dir int) {
+	start, end := t.selection()
+
+	if !t.held[KeyShift] && start != end {
+		if dir < 0 {
+			t.move(start)
+		} else {
+			t.move(end)
+		}
+
+		return
+	}
+
+	t.move(t.caret + dir)
+}
+
+func (t *textEditor) keyDown(r rune) {
+	switch r {
+	case KeyShift, KeyControl, KeyAlt, KeyMeta, KeyAltGraph:
+		t.held[r] = true
+	case KeyBackspace:
+		if !t.deleteSelection() && t.caret > 0 {
+			t.delete(t.caret-1, t.caret)
+		}
+	case KeyDelete:
+		if !t.deleteSelection() && t.caret < len(t.text) {
+			t.delete(t.caret, t.caret+1)
+		}
+	case KeyArrowLeft:
+		t.horizontal(-1)
+	case KeyArrowRight:
+		t.horizontal(1)
+	case KeyArrowUp:
+		t.moveLine(-1)
+	case KeyArrowDown:
+		t.moveLine(1)
+	case KeyHome:
+		t.move(t.lineStart(t.caret))
+	case KeyEnd:
+		t.move(t.lineEnd(t.caret))
+	default:
+		if !IsCharacter(r) || (unicode.IsControl(r) && r != KeyEnter && r != KeyTab) {
+			// Other named keys and control characters do not change the text
+			return
+		}
+
+		if t.held[KeyControl] || t.held[KeyMeta] {
+			// A shortcut. Only select all is modelled, as the contents of the clipboard are unknown.
+
+			if r == 'a' || r == 'A' {
+				t.anchor = 0
+				t.caret = len(t.text)
+			}
+
+			return
+		}
+
+		t.insert(r)
+	}
+}
+
+// Reconstruct replays the events of Recording r against a simulated text field, returning the final text and a log of edits.
+//
+// The caret and selection are modelled: Backspace and Delete remove the selection or the rune before or after the caret,
+// the arrow keys, Home and End move the caret (by line for ArrowUp and ArrowDown), and moving while Shift is held extends the selection.
+// Characters typed while Control or Meta is held are treated as shortcuts, of which only select all (A) is modelled.
+// Auto-repeats are kept, as each one edits the text, such as when Backspace is held.
+func (r *Recording) Reconstruct() *TextReconstruction {
+	return r.ReconstructWithRepeatPolicy(KeepRepeats)
+}
+
+// ReconstructWithRepeatPolicy is like Reconstruct, but handles auto-repeats according to policy.
+// With DropRepeats, a held key is applied once, as though it were pressed once.
+func (r *Recording) ReconstructWithRepeatPolicy(policy RepeatPolicy) *TextReconstruction {
+	t := &textEditor{
+		held: map[rune]bool{},
+	}
+
+	repeats := r.repeats()
+
+	for i, e := range r.Events {
+		if repeats[i] && policy == DropRepeats {
+			continue
+		}
+
+		t.event = e
+		t.eventIndex = i
+
+		switch e.Kind {
+		case KeyDown:
+			t.keyDown(e.Subject)
+		case KeyUp:
+			delete(t.held, e.Subject)
+		}
+	}
+
+	return &TextReconstruction{
+		Text:  string(t.text),
+		Caret: t.caret,
+		Edits: t.edits,
+	}
+}
@@ -0,0 +1,69 @@
+package keyize
+
+import (
+	"testing"
+)
+
+// typeKeys creates a Recording in which each key in keys is pressed and released in turn, 10 units apart.
+func typeKeys(keys ...rune) *Recording {
+	rec := &Recording{}
+
+	for i, k := range keys {
+		rec.Events = append(rec.Events,
+			&RecordingEvent{At: i * 10, Kind: KeyDown, Subject: k},
+			&RecordingEvent{At: i*10 + 5, Kind: KeyUp, Subject: k},
+		)
+	}
+
+	return rec
+}
+
+func TestRecording_Reconstruct(t *testing.T) {
+	// Backspace on empty text must not panic
+
+	if text := typeKeys(KeyBackspace, 'a').Text(); text != "a" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	// Caret movement, Delete and Home/End
+
+	rec := typeKeys('a', 'c', KeyArrowLeft, 'b', KeyHome, KeyDelete, 'x', KeyEnd, '!')
+
+	res := rec.Reconstruct()
+
+	if res.Text != "xbc!" || res.Caret != 4 {
+		t.Fatalf("unexpected text %q, caret %d", res.Text, res.Caret)
+	}
+
+	// Edits are logged with their position and timing
+
+	del := res.Edits[3]
+
+	if len(res.Edits) != 6 || del.Kind != Deletion || del.Text != "a" || del.Position != 0 || del.At != 50 || del.EventIndex != 10 {
+		t.Fatalf("unexpected edit %+v", del)
+	}
+
+	// Shift-selection replaced by typing
+
+	rec = typeKeys('h', 'e', 'l', 'l', 'o')
+
+	rec.Events = append(rec.Events,
+		&RecordingEvent{At: 100, Kind: KeyDown, Subject: KeyShift},
+		&RecordingEvent{At: 110, Kind: KeyDown, Subject: KeyArrowLeft},
+		&RecordingEvent{At: 115, Kind: KeyUp, Subject: KeyArrowLeft},
+		&RecordingEvent{At: 120, Kind: KeyDown, Subject: KeyArrowLeft},
+		&RecordingEvent{At: 125, Kind: KeyUp, Subject: KeyArrowLeft},
+		&RecordingEvent{At: 130, Kind: KeyUp, Subject: KeyShift},
+		&RecordingEvent{At: 140, Kind: KeyDown, Subject: 'p'},
+	)
+
+	if text := rec.Text(); text != "help" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	// Moving between lines keeps the column
+
+	if text := typeKeys('a', 'b', 'c', KeyEnter, 'd', KeyArrowUp, 'X').Text(); text != "aXbc\nd" {
+		t.Fatalf("unexpected text %q", text)
+	}
+}